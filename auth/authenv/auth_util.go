@@ -4,6 +4,11 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // RandStr generate a random string of the given size.
@@ -40,9 +45,70 @@ func LoadEnvironmentVariables() {
 	}
 	tokenExpirationTime := os.Getenv(TokenExpirationKey)
 	if tokenExpirationTime == "" {
-		err := os.Setenv(TokenExpirationKey, string(ExpirationTime))
+		err := os.Setenv(TokenExpirationKey, strconv.Itoa(ExpirationTime))
 		if err != nil {
-			log.Panicf("unable to set default authorization header ->> %s", err)
+			log.Panicf("unable to set default expiration time ->> %s", err)
+		}
+	}
+}
+
+// LoadExpirationTime returns the token lifetime from the os environment, given in
+// TokenExpirationKey as seconds ("86400") or as a duration ("24h"), ExpirationTime
+// seconds when unset.
+func LoadExpirationTime() (time.Duration, error) {
+	value := os.Getenv(TokenExpirationKey)
+	if value == "" {
+		return ExpirationTime * time.Second, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if lifetime, err := time.ParseDuration(value); err == nil && lifetime > 0 {
+		return lifetime, nil
+	}
+	return 0, errors.Errorf("invalid %s %q", TokenExpirationKey, value)
+}
+
+// DefaultExpirationTime for expiration time
+//
+// Deprecated: it is computed once at process start, so it lies further in the
+// past the longer the process runs. Use ExpirationTimeFromNow or LoadExpirationTime.
+var DefaultExpirationTime = time.Now().Add(24 * time.Hour)
+
+// ExpirationTimeFromNow returns the expiration time of a token issued now, falling back
+// to ExpirationTime seconds when TokenExpirationKey is invalid.
+func ExpirationTimeFromNow() time.Time {
+	lifetime, err := LoadExpirationTime()
+	if err != nil {
+		lifetime = ExpirationTime * time.Second
+	}
+	return time.Now().Add(lifetime)
+}
+
+// LoadPrivateKey returns the PEM encoded private key from the os environment,
+// either given directly in PrivateKeyEnvKey or as a file path in PrivateKeyFileEnvKey.
+func LoadPrivateKey() ([]byte, error) {
+	return loadPEM(PrivateKeyEnvKey, PrivateKeyFileEnvKey)
+}
+
+// LoadPublicKey returns the PEM encoded public key from the os environment,
+// either given directly in PublicKeyEnvKey or as a file path in PublicKeyFileEnvKey.
+func LoadPublicKey() ([]byte, error) {
+	return loadPEM(PublicKeyEnvKey, PublicKeyFileEnvKey)
+}
+
+func loadPEM(valueKey, fileKey string) ([]byte, error) {
+	if value := os.Getenv(valueKey); value != "" {
+		// Environment variables usually carry the PEM on a single line with
+		// escaped new lines, put them back so the block can be decoded.
+		return []byte(strings.ReplaceAll(value, `\n`, "\n")), nil
+	}
+	if path := os.Getenv(fileKey); path != "" {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read key file %s", path)
 		}
+		return key, nil
 	}
+	return nil, errors.Errorf("neither %s nor %s is set", valueKey, fileKey)
 }
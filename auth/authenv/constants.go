@@ -1,10 +1,5 @@
 package authenv
 
-import "time"
-
-// DefaultExpirationTime for expiration time
-var DefaultExpirationTime = time.Now().Add(24 * time.Hour)
-
 const (
 	// TokenEnvKey as key
 	TokenEnvKey = "DefaultTokenKey"
@@ -18,10 +13,32 @@ const (
 	AuthorizationHeaderKey = "AuthorizationHeaderKey"
 	// AuthorizationHeader for auth header
 	AuthorizationHeader = "Authorization"
+	// TokenCookie for the cookie browser front-ends keep the token in
+	TokenCookie = "access_token"
+	// CSRFCookie for the cookie holding the double submit csrf token
+	CSRFCookie = "csrf_token"
+	// TokenCookieEnvKey for "true" to let the defaults read the token from the TokenCookie too
+	TokenCookieEnvKey = "DefaultTokenCookie"
+	// CSRFHeader for the header state changing requests echo the csrf token in
+	CSRFHeader = "X-CSRF-Token"
 	// TokenExpirationKey for expiration time
 	TokenExpirationKey = "ExpirationTime"
-	// ExpirationTime as token expiration
+	// ExpirationTime as token expiration, unless the TokenExpirationKey env says otherwise
 	ExpirationTime = (60 * 60 * 24) // in seconds
+	// PrivateKeyEnvKey for the PEM encoded private key used by asymmetric signing methods
+	PrivateKeyEnvKey = "DefaultPrivateKey"
+	// PrivateKeyFileEnvKey for the path of the PEM encoded private key file
+	PrivateKeyFileEnvKey = "DefaultPrivateKeyFile"
+	// PublicKeyEnvKey for the PEM encoded public key used by asymmetric signing methods
+	PublicKeyEnvKey = "DefaultPublicKey"
+	// PublicKeyFileEnvKey for the path of the PEM encoded public key file
+	PublicKeyFileEnvKey = "DefaultPublicKeyFile"
+	// JWKSURLEnvKey for the URL or file path of the key set verification keys are picked from by kid
+	JWKSURLEnvKey = "DefaultJWKSURL"
+	// IssuerEnvKey for the comma separated issuers verifiers accept, the first one is stamped on issued tokens
+	IssuerEnvKey = "DefaultTokenIssuer"
+	// AudienceEnvKey for the comma separated audiences verifiers accept, stamped on issued tokens
+	AudienceEnvKey = "DefaultTokenAudience"
 	// Alphabets that are used for generating default key
 	Alphabets = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ!@#%^&*()_+|?><~1234567890"
 )
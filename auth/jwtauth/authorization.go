@@ -0,0 +1,175 @@
+package jwtauth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Authorizer decides whether the claims of a valid token grant access.
+type Authorizer func(claims jwt.MapClaims) bool
+
+// Require returns a middleware letting through requests whose token the authorizer
+// accepts. Claims already in the request context, put there by Filter or DoFilter,
+// are used as is, otherwise the token is verified first. A missing or invalid token
+// is answered with 401, a valid token without the permission with 403.
+func (v *Verifier) Require(authorizer Authorizer) func(http.Handler) http.Handler {
+	return v.require(authorizer, "")
+}
+
+// require is Require naming the scope that is missing in the insufficient_scope error.
+func (v *Verifier) require(authorizer Authorizer, scope string) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				var authErr *AuthError
+				if claims, authErr = v.authenticate(r); authErr != nil {
+					v.refuse(w, r, authErr)
+					return
+				}
+				r = r.WithContext(v.claimsContext(r, claims))
+			}
+			if !authorizer(claims) {
+				v.refuse(w, r, insufficientScopeError(scope))
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScopes requires the token to carry all the given scopes in its scope or scp claim.
+func (v *Verifier) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return v.require(HasScopes(scopes...), strings.Join(scopes, " "))
+}
+
+// RequireAnyRole requires the token to carry at least one of the given roles in its roles claim.
+func (v *Verifier) RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return v.Require(HasAnyRole(roles...))
+}
+
+// RequireClaim requires the given claim of the token to be, or to contain, the given value.
+func (v *Verifier) RequireClaim(key string, value string) func(http.Handler) http.Handler {
+	return v.Require(HasClaim(key, value))
+}
+
+// RequireScopes is Verifier.RequireScopes with the default verifier.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return requireDefault(func(v *Verifier) func(http.Handler) http.Handler {
+		return v.RequireScopes(scopes...)
+	})
+}
+
+// RequireAnyRole is Verifier.RequireAnyRole with the default verifier.
+func RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return requireDefault(func(v *Verifier) func(http.Handler) http.Handler {
+		return v.RequireAnyRole(roles...)
+	})
+}
+
+// RequireClaim is Verifier.RequireClaim with the default verifier.
+func RequireClaim(key string, value string) func(http.Handler) http.Handler {
+	return requireDefault(func(v *Verifier) func(http.Handler) http.Handler {
+		return v.RequireClaim(key, value)
+	})
+}
+
+func requireDefault(middleware func(v *Verifier) func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verifier, err := DefaultVerifier()
+			if err != nil {
+				http.Error(w, "token verification unavailable", http.StatusInternalServerError)
+				return
+			}
+			middleware(verifier)(handler).ServeHTTP(w, r)
+		})
+	}
+}
+
+// HasScopes accepts claims granting all the given scopes, read from a space
+// separated scope claim or a scp claim holding a string or an array.
+func HasScopes(scopes ...string) Authorizer {
+	return func(claims jwt.MapClaims) bool {
+		granted := append(claimValues(claims, "scope"), claimValues(claims, "scp")...)
+		for _, scope := range scopes {
+			if !contains(granted, scope) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// HasAnyRole accepts claims with at least one of the given roles.
+func HasAnyRole(roles ...string) Authorizer {
+	return func(claims jwt.MapClaims) bool {
+		granted := claimValues(claims, "roles")
+		for _, role := range roles {
+			if contains(granted, role) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasClaim accepts claims where the given claim is, or contains, the given value.
+func HasClaim(key string, value string) Authorizer {
+	return func(claims jwt.MapClaims) bool {
+		return contains(claimValues(claims, key), value)
+	}
+}
+
+// claimValues returns the named claim as a list of strings, looking into the
+// Data of MiniClaims when the claim is not at the top level.
+func claimValues(claims jwt.MapClaims, key string) []string {
+	value, ok := claims[key]
+	if !ok {
+		if data, isMap := claims["Data"].(map[string]interface{}); isMap {
+			value, ok = data[key]
+		}
+	}
+	if !ok {
+		return nil
+	}
+	switch value := value.(type) {
+	case string:
+		// Only scopes are space separated lists, other strings are compared whole.
+		if key == "scope" || key == "scp" {
+			return strings.Fields(value)
+		}
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			values = append(values, claimString(item))
+		}
+		return values
+	case []string:
+		return value
+	default:
+		return []string{claimString(value)}
+	}
+}
+
+// claimString formats a claim value, numbers without exponent so that 1000000 stays 1000000.
+func claimString(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
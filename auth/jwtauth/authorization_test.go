@@ -0,0 +1,105 @@
+package jwtauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestRequireScopes(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	handler := issuer.Verifier().RequireScopes("orders:read")(okHandler())
+
+	for _, test := range []struct {
+		claims jwt.Claims
+		status int
+	}{
+		{jwt.MapClaims{"scope": "orders:read orders:write"}, http.StatusOK},
+		{jwt.MapClaims{"scp": []string{"profile", "orders:read"}}, http.StatusOK},
+		{jwt.MapClaims{"scope": "orders:write"}, http.StatusForbidden},
+		{jwt.MapClaims{}, http.StatusForbidden},
+		{nil, http.StatusUnauthorized},
+	} {
+		code := serveWithClaims(t, issuer, handler, test.claims)
+		if code != test.status {
+			t.Fatalf("expected %d for %v found %d", test.status, test.claims, code)
+		}
+	}
+}
+
+func TestRequireAnyRole(t *testing.T) {
+	issuer, _ := NewIssuer(WithKey(tokenKey))
+	handler := issuer.Verifier().RequireAnyRole("admin", "support")(okHandler())
+
+	if code := serveWithClaims(t, issuer, handler, jwt.MapClaims{"roles": []string{"support"}}); code != http.StatusOK {
+		t.Fatalf("expected %d found %d", http.StatusOK, code)
+	}
+	miniClaims := randomMiniClaims()
+	miniClaims.Data["roles"] = []string{"admin"}
+	if code := serveWithClaims(t, issuer, handler, miniClaims); code != http.StatusOK {
+		t.Fatalf("expected %d for roles in Data found %d", http.StatusOK, code)
+	}
+	if code := serveWithClaims(t, issuer, handler, jwt.MapClaims{"roles": "user"}); code != http.StatusForbidden {
+		t.Fatalf("expected %d found %d", http.StatusForbidden, code)
+	}
+}
+
+func TestRequireClaimAfterFilter(t *testing.T) {
+	issuer, _ := NewIssuer(WithKey(tokenKey))
+	verifier := issuer.Verifier()
+	handler := verifier.Filter(verifier.RequireClaim("tenant", "acme")(okHandler()))
+
+	if code := serveWithClaims(t, issuer, handler, jwt.MapClaims{"tenant": "acme"}); code != http.StatusOK {
+		t.Fatalf("expected %d found %d", http.StatusOK, code)
+	}
+	if code := serveWithClaims(t, issuer, handler, jwt.MapClaims{"tenant": "globex"}); code != http.StatusForbidden {
+		t.Fatalf("expected %d found %d", http.StatusForbidden, code)
+	}
+}
+
+func TestHasClaim(t *testing.T) {
+	for _, test := range []struct {
+		claims jwt.MapClaims
+		key    string
+		value  string
+		found  bool
+	}{
+		{jwt.MapClaims{"name": "John Smith"}, "name", "John Smith", true},
+		{jwt.MapClaims{"name": "John Smith"}, "name", "John", false},
+		{jwt.MapClaims{"scope": "orders:read orders:write"}, "scope", "orders:write", true},
+		{jwt.MapClaims{"scp": "orders:read orders:write"}, "scp", "orders:read", true},
+		{jwt.MapClaims{"account": float64(1000000)}, "account", "1000000", true},
+		{jwt.MapClaims{"ratio": 0.25}, "ratio", "0.25", true},
+		{jwt.MapClaims{"accounts": []interface{}{float64(1000000), "2"}}, "accounts", "1000000", true},
+	} {
+		if found := HasClaim(test.key, test.value)(test.claims); found != test.found {
+			t.Fatalf("expected %t for %s=%q in %v found %t", test.found, test.key, test.value, test.claims, found)
+		}
+	}
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// serveWithClaims serves a request carrying a token for the given claims, or no token at all.
+func serveWithClaims(t *testing.T, issuer *Issuer, handler http.Handler, claims jwt.Claims) int {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if claims != nil {
+		token, err := issuer.Generate(claims)
+		if err != nil {
+			t.Fatalf("error while creating token ->> %s", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code
+}
@@ -0,0 +1,67 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type contextKey int
+
+const (
+	claimsContextKey contextKey = iota
+	tenantContextKey
+)
+
+// ContextWithClaims returns a copy of the context carrying the given claims,
+// which is what the filters do with the claims of a valid token.
+func ContextWithClaims(ctx context.Context, claims jwt.MapClaims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// ContextWithTenant returns a copy of the context carrying the given tenant,
+// which is what the filters of a verifier created WithTenants do.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenant)
+}
+
+// TenantFromContext returns the tenant of the token the filter accepted.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey).(string)
+	return tenant, ok
+}
+
+// ClaimsFromContext returns the claims the filter stored in the request context.
+func ClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(jwt.MapClaims)
+	return claims, ok
+}
+
+// SubjectFromContext returns the sub claim of the token the filter accepted.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	subject, ok := claims["sub"].(string)
+	return subject, ok && subject != ""
+}
+
+// ClaimsFromContextAs decodes the claims the filter stored in the request context
+// into a custom claims type, for example ClaimsFromContextAs[MiniClaims](ctx).
+func ClaimsFromContextAs[T any](ctx context.Context) (*T, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, false
+	}
+	custom := new(T)
+	if err := json.Unmarshal(data, custom); err != nil {
+		return nil, false
+	}
+	return custom, true
+}
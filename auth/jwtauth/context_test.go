@@ -0,0 +1,54 @@
+package jwtauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFilterClaimsInContext(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	// The issuer stamps jti and nbf on claims without them.
+	miniClaims := randomMiniClaims()
+	miniClaims.Id, miniClaims.NotBefore = uuid.NewString(), miniClaims.IssuedAt
+	token, err := issuer.Generate(miniClaims)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	var subject string
+	var contextClaims *MiniClaims
+	handler := issuer.Verifier().Filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, _ = SubjectFromContext(r.Context())
+		contextClaims, _ = ClaimsFromContextAs[MiniClaims](r.Context())
+	}))
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	if subject != miniClaims.Subject {
+		t.Fatalf(`expected subject "%s" found "%s"`, miniClaims.Subject, subject)
+	}
+	if !reflect.DeepEqual(miniClaims, contextClaims) {
+		t.Fatalf("\n expected ->> %v\n found ->> %v", miniClaims, contextClaims)
+	}
+}
+
+func TestClaimsMissingFromContext(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, ok := ClaimsFromContext(request.Context()); ok {
+		t.Fatal("expected no claims in the context")
+	}
+	if _, ok := SubjectFromContext(request.Context()); ok {
+		t.Fatal("expected no subject in the context")
+	}
+	if _, ok := ClaimsFromContextAs[MiniClaims](request.Context()); ok {
+		t.Fatal("expected no custom claims in the context")
+	}
+}
@@ -0,0 +1,121 @@
+package jwtauth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// CookieSettings describes the cookies tokens are kept in for browser front-ends,
+// zero fields take the defaults.
+type CookieSettings struct {
+	// Name of the token cookie, access_token by default.
+	Name string
+	// CSRFName of the csrf token cookie, csrf_token by default.
+	CSRFName string
+	// CSRFHeader the csrf token is echoed in, X-CSRF-Token by default.
+	CSRFHeader string
+	// Path of the cookies, / by default.
+	Path string
+	// Domain of the cookies, the host of the request by default.
+	Domain string
+	// MaxAge of the cookies, a session cookie when zero.
+	MaxAge time.Duration
+	// SameSite mode of the cookies, strict by default.
+	SameSite http.SameSite
+	// Insecure leaves out the Secure attribute, for local development over plain http only.
+	Insecure bool
+}
+
+func (s CookieSettings) withDefaults() CookieSettings {
+	if s.Name == "" {
+		s.Name = authenv.TokenCookie
+	}
+	if s.CSRFName == "" {
+		s.CSRFName = authenv.CSRFCookie
+	}
+	if s.CSRFHeader == "" {
+		s.CSRFHeader = authenv.CSRFHeader
+	}
+	if s.Path == "" {
+		s.Path = "/"
+	}
+	if s.SameSite == 0 {
+		s.SameSite = http.SameSiteStrictMode
+	}
+	return s
+}
+
+func (s CookieSettings) cookie(name string, value string, httpOnly bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     s.Path,
+		Domain:   s.Domain,
+		Secure:   !s.Insecure,
+		HttpOnly: httpOnly,
+		SameSite: s.SameSite,
+	}
+	if s.MaxAge > 0 {
+		cookie.MaxAge = int(s.MaxAge / time.Second)
+		cookie.Expires = time.Now().Add(s.MaxAge)
+	}
+	return cookie
+}
+
+// SetTokenCookie stores the token in an HttpOnly, Secure and SameSite cookie along with
+// a fresh csrf token cookie readable by scripts, whose value is returned as well.
+func SetTokenCookie(w http.ResponseWriter, token string, settings CookieSettings) (csrfToken string, err error) {
+	settings = settings.withDefaults()
+	csrfToken, err = randomToken()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, settings.cookie(settings.Name, token, true))
+	http.SetCookie(w, settings.cookie(settings.CSRFName, csrfToken, false))
+	return csrfToken, nil
+}
+
+// GenerateCookie signs the claims like GenerateWithDefault and stores the token with SetTokenCookie.
+func GenerateCookie(w http.ResponseWriter, claims jwt.Claims, settings CookieSettings) (csrfToken string, err error) {
+	token, err := GenerateWithDefault(claims)
+	if err != nil {
+		return "", err
+	}
+	return SetTokenCookie(w, token, settings)
+}
+
+// GenerateCookie signs the claims and stores the token with SetTokenCookie.
+func (i *Issuer) GenerateCookie(w http.ResponseWriter, claims jwt.Claims, settings CookieSettings) (csrfToken string, err error) {
+	token, err := i.Generate(claims)
+	if err != nil {
+		return "", err
+	}
+	return SetTokenCookie(w, token, settings)
+}
+
+// ClearTokenCookie removes the token and csrf token cookies from the browser.
+func ClearTokenCookie(w http.ResponseWriter, settings CookieSettings) {
+	settings = settings.withDefaults()
+	for _, name := range []string{settings.Name, settings.CSRFName} {
+		cookie := settings.cookie(name, "", name == settings.Name)
+		cookie.MaxAge = -1
+		cookie.Expires = time.Unix(0, 0)
+		http.SetCookie(w, cookie)
+	}
+}
+
+// LogoutHandler clears the token cookies and passes on to the given handler,
+// or answers 204 when there is none.
+func LogoutHandler(settings CookieSettings, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ClearTokenCookie(w, settings)
+		if next == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,122 @@
+package jwtauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+)
+
+func TestGenerateCookie(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	recorder := httptest.NewRecorder()
+	csrfToken, err := issuer.GenerateCookie(recorder, randomMiniClaims(), CookieSettings{})
+	if err != nil {
+		t.Fatalf("error while creating cookie ->> %s", err)
+	}
+
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("expected 2 cookies found %d", len(cookies))
+	}
+	tokenCookie, csrfCookie := cookies[0], cookies[1]
+	if tokenCookie.Name != authenv.TokenCookie || !tokenCookie.HttpOnly || !tokenCookie.Secure || tokenCookie.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("expected an HttpOnly, Secure and SameSite token cookie found %+v", tokenCookie)
+	}
+	if !issuer.Verifier().IsValid(tokenCookie.Value) {
+		t.Fatal("expected the cookie to hold a valid token")
+	}
+	if csrfCookie.Name != authenv.CSRFCookie || csrfCookie.HttpOnly || csrfCookie.Value != csrfToken {
+		t.Fatalf("expected a csrf cookie readable by scripts found %+v", csrfCookie)
+	}
+}
+
+func TestDoFilterCookie(t *testing.T) {
+	t.Setenv(authenv.SigningMethodEnvKey, "HS512")
+	t.Setenv(authenv.TokenEnvKey, "cookie-default-key")
+
+	recorder := httptest.NewRecorder()
+	csrfToken, err := GenerateCookie(recorder, randomMiniClaims(), CookieSettings{})
+	if err != nil {
+		t.Fatalf("error while creating cookie ->> %s", err)
+	}
+	cookies := recorder.Result().Cookies()
+	serve := func(method string, csrfHeader string) int {
+		request := httptest.NewRequest(method, "/", nil)
+		for _, cookie := range cookies {
+			request.AddCookie(cookie)
+		}
+		if csrfHeader != "" {
+			request.Header.Set(authenv.CSRFHeader, csrfHeader)
+		}
+		recorder := httptest.NewRecorder()
+		DoFilter(okHandler()).ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// The cookie is only read once the env opts in.
+	if code := serve(http.MethodGet, ""); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d without opting in found %d", http.StatusUnauthorized, code)
+	}
+	t.Setenv(authenv.TokenCookieEnvKey, "true")
+	if code := serve(http.MethodGet, ""); code != http.StatusOK {
+		t.Fatalf("expected %d found %d", http.StatusOK, code)
+	}
+	if code := serve(http.MethodPost, ""); code != http.StatusForbidden {
+		t.Fatalf("expected %d for a post without csrf token found %d", http.StatusForbidden, code)
+	}
+	if code := serve(http.MethodPost, csrfToken); code != http.StatusOK {
+		t.Fatalf("expected %d for a post with csrf token found %d", http.StatusOK, code)
+	}
+}
+
+func TestCSRFProtect(t *testing.T) {
+	handler := CSRFProtect(CookieSettings{})(okHandler())
+	serve := func(method string, cookies map[string]string, header string) int {
+		request := httptest.NewRequest(method, "/", nil)
+		for name, value := range cookies {
+			request.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		if header != "" {
+			request.Header.Set(authenv.CSRFHeader, header)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	session := map[string]string{authenv.TokenCookie: "token", authenv.CSRFCookie: "csrf-value"}
+
+	if code := serve(http.MethodGet, session, ""); code != http.StatusOK {
+		t.Fatalf("expected safe method to pass found %d", code)
+	}
+	if code := serve(http.MethodPost, session, "csrf-value"); code != http.StatusOK {
+		t.Fatalf("expected matching csrf token to pass found %d", code)
+	}
+	if code := serve(http.MethodPost, session, ""); code != http.StatusForbidden {
+		t.Fatalf("expected missing csrf token to be refused found %d", code)
+	}
+	if code := serve(http.MethodPost, session, "other-value"); code != http.StatusForbidden {
+		t.Fatalf("expected wrong csrf token to be refused found %d", code)
+	}
+	if code := serve(http.MethodPost, nil, ""); code != http.StatusOK {
+		t.Fatalf("expected request without token cookie to pass found %d", code)
+	}
+}
+
+func TestLogoutHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	LogoutHandler(CookieSettings{}, nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/logout", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected %d found %d", http.StatusNoContent, recorder.Code)
+	}
+	for _, header := range recorder.Header().Values("Set-Cookie") {
+		if !strings.Contains(header, "Max-Age=0") {
+			t.Fatalf("expected the cookie to be cleared found %s", header)
+		}
+	}
+}
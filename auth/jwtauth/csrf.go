@@ -0,0 +1,49 @@
+package jwtauth
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// CSRFProtect returns a middleware protecting cookie based sessions with the double submit
+// pattern. Requests with a state changing method that carry the token cookie must echo the
+// value of the csrf token cookie in the csrf header, or in a form field of the same name as
+// the csrf cookie, otherwise they are answered with 403. Requests authenticated otherwise,
+// for example with an Authorization header, are not exposed to csrf and pass as they are.
+func CSRFProtect(settings CookieSettings) func(http.Handler) http.Handler {
+	settings = settings.withDefaults()
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			if _, err := r.Cookie(settings.Name); err != nil {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			csrfCookie, err := r.Cookie(settings.CSRFName)
+			if err != nil || csrfCookie.Value == "" {
+				http.Error(w, "missing csrf token", http.StatusForbidden)
+				return
+			}
+			submitted := r.Header.Get(settings.CSRFHeader)
+			if submitted == "" {
+				submitted = r.PostFormValue(settings.CSRFName)
+			}
+			if subtle.ConstantTimeCompare([]byte(submitted), []byte(csrfCookie.Value)) != 1 {
+				http.Error(w, "invalid csrf token", http.StatusForbidden)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
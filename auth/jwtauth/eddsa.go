@@ -0,0 +1,56 @@
+package jwtauth
+
+import (
+	"crypto/ed25519"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// ErrEd25519Verification is returned when an EdDSA signature does not match.
+var ErrEd25519Verification = errors.New("ed25519: verification error")
+
+// SigningMethodEd25519 implements the EdDSA signing method with Ed25519 keys,
+// which jwt-go does not provide. It expects ed25519.PrivateKey for signing
+// and ed25519.PublicKey for verification.
+type SigningMethodEd25519 struct{}
+
+// SigningMethodEdDSA is the registered instance for the "EdDSA" alg.
+var SigningMethodEdDSA *SigningMethodEd25519
+
+func init() {
+	SigningMethodEdDSA = &SigningMethodEd25519{}
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// Alg returns the alg identifier of the signing method.
+func (m *SigningMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+// Verify the signature of the signing string with the given public key.
+func (m *SigningMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(publicKey) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return ErrEd25519Verification
+	}
+	return nil
+}
+
+// Sign the signing string with the given private key.
+func (m *SigningMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
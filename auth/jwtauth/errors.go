@@ -0,0 +1,140 @@
+package jwtauth
+
+import (
+	"fmt"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// ErrTokenExpired is returned for tokens past their exp, or older than the max age.
+var ErrTokenExpired = errors.New("token is expired")
+
+// ErrTokenNotYetValid is returned for tokens before their nbf or iat.
+var ErrTokenNotYetValid = errors.New("token is not valid yet")
+
+// ErrSignatureInvalid is returned for tokens whose signature does not match the key.
+var ErrSignatureInvalid = errors.New("token signature is invalid")
+
+// ErrMissingKey is returned when no key is found to verify a token with, for example
+// for a kid the key set does not hold.
+var ErrMissingKey = errors.New("no key to verify the token with")
+
+// ErrNoToken is returned by extractors for requests that carry no token.
+var ErrNoToken = errors.New("no token found")
+
+// ErrMalformed is returned for tokens, or headers carrying them, that cannot be read.
+var ErrMalformed = errors.New("token is malformed")
+
+// ErrAlgorithmNotAllowed is returned for tokens signed with a method the verifier does not accept.
+var ErrAlgorithmNotAllowed = errors.New("signing method not allowed")
+
+// ErrRevoked is returned for tokens denied by the revocation store.
+var ErrRevoked = errors.New("token is revoked")
+
+// ErrIssuerMismatch is returned for tokens from an issuer the verifier does not accept.
+var ErrIssuerMismatch = errors.New("token issuer is not accepted")
+
+// ErrAudienceMismatch is returned for tokens meant for another audience.
+var ErrAudienceMismatch = errors.New("token audience is not accepted")
+
+// ErrMissingClaim is returned for tokens lacking a claim they need.
+var ErrMissingClaim = errors.New("token is missing a claim")
+
+// ErrInvalidClaim is returned for tokens with a claim of the wrong type.
+var ErrInvalidClaim = errors.New("token has an invalid claim")
+
+// ErrUnknownTenant is returned by routing verifiers for tokens of no tenant they know, see WithTenants.
+var ErrUnknownTenant = errors.New("token tenant is not known")
+
+// ErrSessionExpired is returned when refreshing a token would outlive the maximum session lifetime.
+var ErrSessionExpired = errors.New("session reached its maximum lifetime")
+
+// ClaimError names the claim a token was rejected for, it matches ErrMissingClaim or ErrInvalidClaim.
+type ClaimError struct {
+	Claim string
+	Err   error
+}
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Claim)
+}
+
+// Unwrap returns ErrMissingClaim or ErrInvalidClaim.
+func (e *ClaimError) Unwrap() error {
+	return e.Err
+}
+
+// AlgorithmError reports the signing method of a rejected token, it matches ErrAlgorithmNotAllowed.
+type AlgorithmError struct {
+	Algorithm string
+}
+
+func (e *AlgorithmError) Error() string {
+	return fmt.Sprintf("signing method %s is not allowed", e.Algorithm)
+}
+
+// Is makes errors.Is(err, ErrAlgorithmNotAllowed) hold.
+func (e *AlgorithmError) Is(target error) bool {
+	return target == ErrAlgorithmNotAllowed
+}
+
+// TokenError is returned for tokens jwt-go failed to parse or validate, errors.Is matches
+// it against the sentinel error of the reason while errors.As still finds the jwt-go error.
+type TokenError struct {
+	// Reason is one of ErrTokenExpired, ErrTokenNotYetValid, ErrSignatureInvalid,
+	// ErrMalformed or ErrMissingKey.
+	Reason error
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *TokenError) Error() string {
+	if e.Err == nil {
+		return e.Reason.Error()
+	}
+	return e.Err.Error()
+}
+
+// Is makes errors.Is(err, e.Reason) hold.
+func (e *TokenError) Is(target error) bool {
+	return target == e.Reason
+}
+
+// Unwrap returns the underlying error.
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// tokenError turns the errors of jwt-go, which do not support errors.Is, into a TokenError,
+// and returns our own errors it wrapped into a ValidationError as they are.
+func tokenError(err error) error {
+	validationErr, ok := err.(*jwt.ValidationError)
+	if !ok {
+		return err
+	}
+	switch inner := validationErr.Inner.(type) {
+	case *AlgorithmError, *TokenError:
+		return inner
+	}
+	switch {
+	case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return &TokenError{Reason: ErrMalformed, Err: err}
+	case validationErr.Errors&jwt.ValidationErrorExpired != 0:
+		return &TokenError{Reason: ErrTokenExpired, Err: err}
+	case validationErr.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+		return &TokenError{Reason: ErrTokenNotYetValid, Err: err}
+	case validationErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return &TokenError{Reason: ErrSignatureInvalid, Err: err}
+	case validationErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return &TokenError{Reason: ErrMissingKey, Err: err}
+	}
+	return err
+}
+
+// ErrRefreshTokenInvalid is returned for refresh tokens that are unknown, expired or revoked.
+var ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
+
+// ErrRefreshTokenReused is returned when a refresh token is used again after it was rotated,
+// the whole family it belongs to is revoked by then.
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
@@ -0,0 +1,68 @@
+package jwtauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestValidateReasons(t *testing.T) {
+	now := time.Now()
+	sign := func(claims jwt.MapClaims, opts ...Option) string {
+		issuer, err := NewIssuer(append([]Option{WithKey(tokenKey)}, opts...)...)
+		if err != nil {
+			t.Fatalf("error creating issuer ->> %s", err)
+		}
+		token, err := issuer.Generate(claims)
+		if err != nil {
+			t.Fatalf("error while creating token ->> %s", err)
+		}
+		return token
+	}
+	valid := sign(jwt.MapClaims{"sub": "someone"})
+
+	tests := []struct {
+		name   string
+		token  string
+		opts   []Option
+		reason error
+	}{
+		{"expired", sign(jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}), nil, ErrTokenExpired},
+		{"not yet valid", sign(jwt.MapClaims{"nbf": now.Add(time.Hour).Unix()}), nil, ErrTokenNotYetValid},
+		{"signature", valid[:len(valid)-4] + "AAAA", nil, ErrSignatureInvalid},
+		{"malformed", "not.a.token", nil, ErrMalformed},
+		{"algorithm", valid, []Option{WithAlgorithm("HS256")}, ErrAlgorithmNotAllowed},
+		{"audience", valid, []Option{WithAudiences("billing")}, ErrAudienceMismatch},
+		{"missing key", sign(jwt.MapClaims{}, WithKeyID("unknown")), []Option{WithKeyResolver(&JSONWebKeySet{})}, ErrMissingKey},
+	}
+	for _, test := range tests {
+		err := Validate(test.token, tokenKey, test.opts...)
+		if !errors.Is(err, test.reason) {
+			t.Fatalf("%s: expected %v found %v", test.name, test.reason, err)
+		}
+	}
+	if err := Validate(valid, tokenKey); err != nil {
+		t.Fatalf("expected the token to be valid ->> %s", err)
+	}
+}
+
+func TestTokenErrorKeepsValidationError(t *testing.T) {
+	token, err := Generate("HS512", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	err = Validate(token, tokenKey)
+	var tokenErr *TokenError
+	if !errors.As(err, &tokenErr) || tokenErr.Reason != ErrTokenExpired {
+		t.Fatalf("expected a TokenError for ErrTokenExpired found %v", err)
+	}
+	var validationErr *jwt.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Errors&jwt.ValidationErrorExpired == 0 {
+		t.Fatalf("expected the jwt-go validation error to be kept found %v", err)
+	}
+	if err.Error() != "Token is expired" {
+		t.Fatalf("expected the message of jwt-go found %s", err)
+	}
+}
@@ -0,0 +1,110 @@
+package jwtauth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Extractor pulls the token out of a request. It returns ErrNoToken when the
+// request carries none and ErrMalformed when it carries one it cannot read.
+type Extractor interface {
+	Extract(r *http.Request) (string, error)
+}
+
+// ExtractorFunc makes an ordinary function an Extractor.
+type ExtractorFunc func(r *http.Request) (string, error)
+
+// Extract calls the function.
+func (f ExtractorFunc) Extract(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// BearerExtractor reads the token from the given header using the Bearer scheme,
+// whose name is matched case-insensitively. It is what verifiers use by default.
+func BearerExtractor(header string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		headerValue := strings.TrimSpace(r.Header.Get(header))
+		if headerValue == "" {
+			return "", ErrNoToken
+		}
+		scheme, token, found := strings.Cut(headerValue, " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			// Credentials of another scheme are no bearer token.
+			return "", ErrNoToken
+		}
+		return rawToken(token, found)
+	})
+}
+
+// HeaderExtractor reads the raw token, without scheme, from the given header.
+func HeaderExtractor(header string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		token := strings.TrimSpace(r.Header.Get(header))
+		if token == "" {
+			return "", ErrNoToken
+		}
+		return rawToken(token, true)
+	})
+}
+
+// CookieExtractor reads the token from the cookie with the given name.
+func CookieExtractor(name string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", ErrNoToken
+		}
+		return rawToken(cookie.Value, true)
+	})
+}
+
+// QueryExtractor reads the token from the given query parameter. Tokens in URLs
+// end up in logs and browser history, prefer the other extractors where possible.
+func QueryExtractor(param string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		token := r.URL.Query().Get(param)
+		if token == "" {
+			return "", ErrNoToken
+		}
+		return rawToken(token, true)
+	})
+}
+
+// MultiExtractor tries the given extractors in order and returns the first token found.
+func MultiExtractor(extractors ...Extractor) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		for _, extractor := range extractors {
+			token, err := extractor.Extract(r)
+			if err != ErrNoToken {
+				return token, err
+			}
+		}
+		return "", ErrNoToken
+	})
+}
+
+// headerToken returns the token of an authorization header value, which is
+// either the raw token or the token following the Bearer scheme.
+func headerToken(headerValue string) (string, error) {
+	headerValue = strings.TrimSpace(headerValue)
+	if headerValue == "" {
+		return "", ErrNoToken
+	}
+	scheme, token, found := strings.Cut(headerValue, " ")
+	if !found {
+		return rawToken(scheme, true)
+	}
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrMalformed
+	}
+	return rawToken(token, true)
+}
+
+// rawToken makes sure the token is a single non empty word.
+func rawToken(token string, found bool) (string, error) {
+	token = strings.TrimSpace(token)
+	if !found || token == "" || strings.ContainsAny(token, " \t\r\n") {
+		return "", ErrMalformed
+	}
+	return token, nil
+}
@@ -0,0 +1,94 @@
+package jwtauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShortHeaderDoesNotPanic(t *testing.T) {
+	for _, headerValue := range []string{"abc", "", "Bearer", "Bearer  ", "Basic dXNlcjpwYXNz"} {
+		if IsValid(headerValue, tokenKey) {
+			t.Fatalf("expected %q to be invalid", headerValue)
+		}
+		if _, err := ParseToken(headerValue, tokenKey); err == nil {
+			t.Fatalf("expected error parsing %q", headerValue)
+		}
+	}
+	if _, err := ParseToken("Token abc.def.ghi", tokenKey); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected ErrMalformed found %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "abc")
+	DoFilter(okHandler()).ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestRawAndCaseInsensitiveTokens(t *testing.T) {
+	token, err := Generate("HS512", randomMiniClaims(), tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	for _, headerValue := range []string{token, "bearer " + token, "BEARER  " + token} {
+		if !IsValid(headerValue, tokenKey) {
+			t.Fatalf("expected %q to be valid", headerValue)
+		}
+	}
+}
+
+func TestExtractors(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	token, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	for name, test := range map[string]struct {
+		extractor Extractor
+		prepare   func(r *http.Request)
+	}{
+		"bearer": {BearerExtractor("Authorization"), func(r *http.Request) { r.Header.Set("Authorization", "bearer "+token) }},
+		"header": {HeaderExtractor("X-Api-Key"), func(r *http.Request) { r.Header.Set("X-Api-Key", token) }},
+		"cookie": {CookieExtractor("session"), func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: token}) }},
+		"query":  {QueryExtractor("access_token"), func(r *http.Request) { r.URL.RawQuery = "access_token=" + token }},
+		"multi": {MultiExtractor(BearerExtractor("Authorization"), CookieExtractor("session")), func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "session", Value: token})
+		}},
+	} {
+		verifier, err := NewVerifier(WithKey(tokenKey), WithExtractor(test.extractor))
+		if err != nil {
+			t.Fatalf("error creating verifier ->> %s", err)
+		}
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		test.prepare(request)
+		recorder := httptest.NewRecorder()
+		verifier.Filter(okHandler()).ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d found %d", name, http.StatusOK, recorder.Code)
+		}
+	}
+}
+
+func TestBearerExtractorErrors(t *testing.T) {
+	extractor := BearerExtractor("Authorization")
+	for headerValue, expected := range map[string]error{
+		"":                   ErrNoToken,
+		"Basic dXNlcjpwYXNz": ErrNoToken,
+		"Bearer":             ErrMalformed,
+		"Bearer a b":         ErrMalformed,
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Authorization", headerValue)
+		if _, err := extractor.Extract(request); err != expected {
+			t.Fatalf("%q: expected %v found %v", headerValue, expected, err)
+		}
+	}
+}
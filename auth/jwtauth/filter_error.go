@@ -0,0 +1,166 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Error codes of RFC 6750 section 3.1.
+const (
+	ErrorCodeInvalidRequest    = "invalid_request"
+	ErrorCodeInvalidToken      = "invalid_token"
+	ErrorCodeInsufficientScope = "insufficient_scope"
+)
+
+// AuthError describes why a filter refused a request, in the terms of RFC 6750.
+type AuthError struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// Code is one of the ErrorCode constants, empty when no credentials were sent.
+	Code string
+	// Description is a human readable explanation for the client.
+	Description string
+	// Realm is the protection realm of the verifier, if any.
+	Realm string
+	// Scope lists the scopes the request needed when Code is insufficient_scope.
+	Scope string
+	// Err is the underlying parse or validation error, if any.
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	if e.Code == "" {
+		return e.Description
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// Unwrap returns the underlying parse or validation error.
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// WWWAuthenticate returns the value of the WWW-Authenticate header for the error.
+func (e *AuthError) WWWAuthenticate() string {
+	var params []string
+	if e.Realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", e.Realm))
+	}
+	if e.Code != "" {
+		params = append(params, fmt.Sprintf("error=%q", e.Code))
+		if e.Description != "" {
+			params = append(params, fmt.Sprintf("error_description=%q", e.Description))
+		}
+	}
+	if e.Scope != "" {
+		params = append(params, fmt.Sprintf("scope=%q", e.Scope))
+	}
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// ErrorHandler writes the response for a request a filter refused.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, authErr *AuthError)
+
+// BearerErrorHandler answers with the WWW-Authenticate header of RFC 6750 and
+// the description as plain text body, it is what filters use by default.
+func BearerErrorHandler(w http.ResponseWriter, r *http.Request, authErr *AuthError) {
+	w.Header().Set("WWW-Authenticate", authErr.WWWAuthenticate())
+	http.Error(w, authErr.Error(), authErr.Status)
+}
+
+// ProblemErrorHandler answers with the WWW-Authenticate header of RFC 6750 and
+// an application/problem+json body as described in RFC 7807.
+func ProblemErrorHandler(w http.ResponseWriter, r *http.Request, authErr *AuthError) {
+	w.Header().Set("WWW-Authenticate", authErr.WWWAuthenticate())
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(authErr.Status)
+	problem := map[string]interface{}{
+		"type":   "about:blank",
+		"title":  http.StatusText(authErr.Status),
+		"status": authErr.Status,
+		"detail": authErr.Description,
+	}
+	if authErr.Code != "" {
+		problem["error"] = authErr.Code
+	}
+	if authErr.Scope != "" {
+		problem["scope"] = authErr.Scope
+	}
+	json.NewEncoder(w).Encode(problem)
+}
+
+// NewAuthError describes the refusal of a request for the error an Extractor or the validation
+// of the token returned, so that filters for other kinds of tokens answer like the jwt ones do:
+// ErrNoToken without error code, the ErrMalformed of extractors with invalid_request and any
+// other error with invalid_token.
+func NewAuthError(err error) *AuthError {
+	switch err {
+	case ErrNoToken:
+		return missingTokenError()
+	case ErrMalformed:
+		return invalidRequestError(err)
+	}
+	return invalidTokenError(err)
+}
+
+// missingTokenError is the answer to a request without credentials, which
+// per RFC 6750 carries no error code.
+func missingTokenError() *AuthError {
+	return &AuthError{
+		Status:      http.StatusUnauthorized,
+		Description: "missing access token",
+	}
+}
+
+// invalidRequestError tells the client its credentials could not be read.
+func invalidRequestError(err error) *AuthError {
+	return &AuthError{
+		Status:      http.StatusBadRequest,
+		Code:        ErrorCodeInvalidRequest,
+		Description: "the access token could not be read from the request",
+		Err:         err,
+	}
+}
+
+// invalidTokenError tells the client why its token was not accepted.
+func invalidTokenError(err error) *AuthError {
+	description := "the access token is invalid"
+	switch {
+	case errors.Is(err, ErrMalformed):
+		description = "the access token is malformed"
+	case errors.Is(err, ErrTokenExpired):
+		description = "the access token expired"
+	case errors.Is(err, ErrTokenNotYetValid):
+		description = "the access token is not valid yet"
+	case errors.Is(err, ErrRevoked):
+		description = "the access token was revoked"
+	case errors.Is(err, ErrIssuerMismatch), errors.Is(err, ErrAudienceMismatch), errors.Is(err, ErrUnknownTenant):
+		description = "the access token is not meant for this service"
+	case errors.Is(err, ErrMissingClaim), errors.Is(err, ErrInvalidClaim):
+		description = "the access token has a missing or invalid claim"
+	}
+	return &AuthError{
+		Status:      http.StatusUnauthorized,
+		Code:        ErrorCodeInvalidToken,
+		Description: description,
+		Err:         err,
+	}
+}
+
+// insufficientScopeError tells the client its valid token lacks the permission.
+func insufficientScopeError(scope string) *AuthError {
+	return &AuthError{
+		Status:      http.StatusForbidden,
+		Code:        ErrorCodeInsufficientScope,
+		Description: "the access token lacks the required permission",
+		Scope:       scope,
+	}
+}
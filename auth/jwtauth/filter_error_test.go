@@ -0,0 +1,96 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestFilterErrorResponses(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey), WithRealm("orders"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	expired, err := issuer.Generate(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	valid, err := issuer.Generate(jwt.MapClaims{"scope": "orders:write"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	verifier := issuer.Verifier()
+	handler := verifier.Filter(verifier.RequireScopes("orders:read")(okHandler()))
+
+	for _, test := range []struct {
+		header          string
+		status          int
+		wwwAuthenticate string
+	}{
+		{"", http.StatusUnauthorized, `Bearer realm="orders"`},
+		{"Bearer " + expired, http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="the access token expired"`},
+		{"Bearer not.a.token", http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="the access token is malformed"`},
+		{"Bearer " + valid, http.StatusForbidden, `Bearer realm="orders", error="insufficient_scope", error_description="the access token lacks the required permission", scope="orders:read"`},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.header != "" {
+			request.Header.Set("Authorization", test.header)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Fatalf("expected %d found %d", test.status, recorder.Code)
+		}
+		if found := recorder.Header().Get("WWW-Authenticate"); found != test.wwwAuthenticate {
+			t.Fatalf("\n expected ->> %s\n found ->> %s", test.wwwAuthenticate, found)
+		}
+	}
+}
+
+func TestProblemErrorHandler(t *testing.T) {
+	verifier, err := NewVerifier(WithKey(tokenKey), WithErrorHandler(ProblemErrorHandler))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	recorder := httptest.NewRecorder()
+	verifier.Filter(okHandler()).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Fatalf("expected application/problem+json found %s", contentType)
+	}
+	problem := map[string]interface{}{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("error decoding problem ->> %s", err)
+	}
+	if problem["status"] != float64(http.StatusUnauthorized) {
+		t.Fatalf("expected status %d found %v", http.StatusUnauthorized, problem["status"])
+	}
+}
+
+func TestNewAuthError(t *testing.T) {
+	for _, test := range []struct {
+		err         error
+		status      int
+		code        string
+		description string
+	}{
+		{ErrNoToken, http.StatusUnauthorized, "", "missing access token"},
+		{ErrMalformed, http.StatusBadRequest, ErrorCodeInvalidRequest, "the access token could not be read from the request"},
+		{&TokenError{Reason: ErrTokenExpired}, http.StatusUnauthorized, ErrorCodeInvalidToken, "the access token expired"},
+		{ErrUnknownTenant, http.StatusUnauthorized, ErrorCodeInvalidToken, "the access token is not meant for this service"},
+		{errors.New("token is not active"), http.StatusUnauthorized, ErrorCodeInvalidToken, "the access token is invalid"},
+	} {
+		authErr := NewAuthError(test.err)
+		if authErr.Status != test.status || authErr.Code != test.code || authErr.Description != test.description {
+			t.Fatalf("expected %d %q %q for %v found %d %q %q", test.status, test.code, test.description, test.err, authErr.Status, authErr.Code, authErr.Description)
+		}
+	}
+}
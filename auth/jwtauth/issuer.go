@@ -0,0 +1,237 @@
+package jwtauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Issuer signs tokens with its own key and settings.
+type Issuer struct {
+	method   jwt.SigningMethod
+	keys     *keyCache
+	keyID    string
+	keyring  *Keyring
+	expiry   time.Duration
+	window   time.Duration
+	maxAge   time.Duration
+	verifier *Verifier
+}
+
+// NewIssuer creates an issuer with the given options, a key or a keyring is required.
+// For asymmetric signing methods the key is the PEM encoded private key.
+func NewIssuer(opts ...Option) (*Issuer, error) {
+	c := newConfig(opts)
+	method := jwt.GetSigningMethod(c.algorithm)
+	if method == nil {
+		return nil, errors.New("invalid signing method")
+	}
+	keys := newKeyCache(c.key)
+	allowed := []string{method.Alg()}
+	var resolver KeyResolver
+	if c.keyring != nil {
+		// The signing method follows the current key of the keyring.
+		allowed, resolver = c.allowed, c.keyring
+	} else {
+		if len(c.key) == 0 {
+			return nil, errors.New("invalid key")
+		}
+		if _, err := keys.signingKey(method.Alg()); err != nil {
+			return nil, err
+		}
+	}
+	return &Issuer{
+		method:   method,
+		keys:     keys,
+		keyID:    c.keyID,
+		keyring:  c.keyring,
+		expiry:   c.expiry,
+		window:   c.refreshWindow,
+		maxAge:   c.maxSession,
+		verifier: newVerifier(c, keys, resolver, allowed),
+	}, nil
+}
+
+// Verifier returns a verifier that accepts the tokens of this issuer.
+func (i *Issuer) Verifier() *Verifier {
+	return i.verifier
+}
+
+// Generate signs the given claims. Unless they carry their own, the token is stamped
+// with a unique jti so that it can be revoked, with iat and nbf set to now and exp
+// set to now plus the expiry of the issuer, and with the iss and aud the issuer was
+// configured with.
+func (i *Issuer) Generate(claims jwt.Claims) (token string, err error) {
+	return i.GenerateWithExpiry(claims, i.expiry)
+}
+
+// GenerateWithExpiry signs the given claims like Generate, with exp set to now plus
+// the given expiry unless the claims carry their own.
+func (i *Issuer) GenerateWithExpiry(claims jwt.Claims, expiry time.Duration) (token string, err error) {
+	if claims == nil {
+		return "", errors.New("invalid claims")
+	}
+	if expiry <= 0 {
+		return "", errors.New("invalid expiry")
+	}
+	mapClaims, err := toMapClaims(claims)
+	if err != nil {
+		return "", err
+	}
+	i.stamp(mapClaims, expiry)
+	return i.sign(mapClaims)
+}
+
+// stamp adds the jti, time based, iss and aud claims the given claims do not carry.
+func (i *Issuer) stamp(mapClaims jwt.MapClaims, expiry time.Duration) {
+	now := time.Now()
+	if jti, _ := mapClaims["jti"].(string); jti == "" {
+		mapClaims["jti"] = uuid.NewString()
+	}
+	if _, found := mapClaims["iat"]; !found {
+		mapClaims["iat"] = now.Unix()
+	}
+	if _, found := mapClaims["nbf"]; !found {
+		mapClaims["nbf"] = now.Unix()
+	}
+	if _, found := mapClaims["exp"]; !found {
+		mapClaims["exp"] = now.Add(expiry).Unix()
+	}
+	if _, found := mapClaims["iss"]; !found && len(i.verifier.issuers) > 0 {
+		mapClaims["iss"] = i.verifier.issuers[0]
+	}
+	if _, found := mapClaims["aud"]; !found {
+		switch audiences := i.verifier.audiences; len(audiences) {
+		case 0:
+		case 1:
+			mapClaims["aud"] = audiences[0]
+		default:
+			mapClaims["aud"] = audiences
+		}
+	}
+}
+
+// sign signs the given claims as they are.
+func (i *Issuer) sign(mapClaims jwt.MapClaims) (token string, err error) {
+	kid, method, signingKey, err := i.signingKey(i.method)
+	if err != nil {
+		return "", err
+	}
+	return generateToken(method, mapClaims, signingKey, kid)
+}
+
+// toMapClaims returns a copy of the given claims as map claims, numbers are kept as json.Number
+// so they are signed exactly as they were given.
+func toMapClaims(claims jwt.Claims) (jwt.MapClaims, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid claims")
+	}
+	mapClaims := jwt.MapClaims{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&mapClaims); err != nil || mapClaims == nil {
+		return nil, errors.New("invalid claims")
+	}
+	return mapClaims, nil
+}
+
+// signingKey returns the current key of the keyring, or else the
+// key of the issuer for the given signing method.
+func (i *Issuer) signingKey(method jwt.SigningMethod) (kid string, signingMethod jwt.SigningMethod, signingKey interface{}, err error) {
+	if i.keyring != nil {
+		kid, signingMethod, signingKey = i.keyring.signing()
+		return kid, signingMethod, signingKey, nil
+	}
+	signingKey, err = i.keys.signingKey(method.Alg())
+	return i.keyID, method, signingKey, err
+}
+
+// Algorithm returns the signing method tokens are signed with, the one of the current
+// key when the issuer has a keyring.
+func (i *Issuer) Algorithm() string {
+	if i.keyring != nil {
+		_, method, _ := i.keyring.signing()
+		return method.Alg()
+	}
+	return i.method.Alg()
+}
+
+// KeySet returns the public keys of the issuer for publishing with JWKSHandler. The set
+// is empty for HMAC signing methods, a shared secret is never published.
+func (i *Issuer) KeySet() (*JSONWebKeySet, error) {
+	if i.keyring != nil {
+		return i.keyring.KeySet()
+	}
+	if !IsAsymmetric(i.method.Alg()) {
+		return &JSONWebKeySet{Keys: []JSONWebKey{}}, nil
+	}
+	signingKey, err := i.keys.signingKey(i.method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	jwk, err := NewJSONWebKey(i.keyID, i.method.Alg(), signingKey)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONWebKeySet{Keys: []JSONWebKey{*jwk}}, nil
+}
+
+// Refresh resets the expiration time of the given token, unless more than the refresh
+// window is left in which case it is returned as is. With a maximum session lifetime the
+// new expiration time never goes past that lifetime counted from the auth_time claim, or
+// the iat claim of the first token, and once it is reached ErrSessionExpired is returned.
+func (i *Issuer) Refresh(token string) (newToken string, err error) {
+	parseToken, err := i.verifier.parse(nil, token, jwt.MapClaims{})
+	if err != nil {
+		return "", err
+	}
+	mapClaims := parseToken.Claims.(jwt.MapClaims)
+	exp, ok := numericClaim(mapClaims, "exp")
+	if !ok {
+		return "", &ClaimError{Claim: "exp", Err: ErrMissingClaim}
+	}
+
+	// Unless the token is about to expire before renewing it, otherwise,
+	// just return the token to user, to avoid unnecessary creation of token.
+	now := time.Now()
+	if i.window > 0 && time.Unix(exp, 0).Sub(now) > i.window {
+		return token, nil
+	}
+
+	// The session started when the user authenticated, which later refreshes
+	// need to know once iat moves on.
+	authTime, ok := numericClaim(mapClaims, "auth_time")
+	if !ok {
+		if authTime, ok = numericClaim(mapClaims, "iat"); ok {
+			mapClaims["auth_time"] = authTime
+		}
+	}
+
+	// The token is about to expire, creat a new token for the user
+	expiresAt := now.Add(i.expiry)
+	if i.maxAge > 0 {
+		if !ok {
+			return "", &ClaimError{Claim: "auth_time", Err: ErrMissingClaim}
+		}
+		sessionEnd := time.Unix(authTime, 0).Add(i.maxAge)
+		if !now.Before(sessionEnd) {
+			return "", ErrSessionExpired
+		}
+		if sessionEnd.Before(expiresAt) {
+			expiresAt = sessionEnd
+		}
+	}
+	mapClaims["iat"] = now.Unix()
+	mapClaims["exp"] = expiresAt.Unix() // reset the expiration time
+	mapClaims["jti"] = uuid.NewString() // so revoking the old token spares the new one
+	kid, method, signingKey, err := i.signingKey(parseToken.Method)
+	if err != nil {
+		return "", err
+	}
+	return generateToken(method, mapClaims, signingKey, kid)
+}
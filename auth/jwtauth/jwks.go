@@ -0,0 +1,306 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// maxKeySetSize bounds how much of a key set response is read.
+const maxKeySetSize = 1 << 20
+
+// minRefetchInterval keeps tokens with unknown key ids from hammering the key set source.
+const minRefetchInterval = 10 * time.Second
+
+// JSONWebKey is the public part of a signing key as described in RFC 7517.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a set of public keys as published by a JWKS endpoint.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// KeySetSource provides the key set published by JWKSHandler.
+type KeySetSource interface {
+	KeySet() (*JSONWebKeySet, error)
+}
+
+// KeyResolver resolves the key a token is verified with, usually by its kid header.
+type KeyResolver interface {
+	ResolveKey(parseToken *jwt.Token) (interface{}, error)
+}
+
+// NewJSONWebKey describes the given RSA, ECDSA or Ed25519 public key. A private key is
+// accepted as well, only its public half ends up in the result.
+func NewJSONWebKey(kid string, signingMethod string, publicKey interface{}) (*JSONWebKey, error) {
+	jwk := &JSONWebKey{Kid: kid, Use: "sig", Alg: signingMethod}
+	switch key := publicKey.(type) {
+	case *rsa.PrivateKey:
+		return NewJSONWebKey(kid, signingMethod, &key.PublicKey)
+	case *ecdsa.PrivateKey:
+		return NewJSONWebKey(kid, signingMethod, &key.PublicKey)
+	case ed25519.PrivateKey:
+		return NewJSONWebKey(kid, signingMethod, key.Public())
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64(key.N.Bytes())
+		jwk.E = encodeBase64(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = encodeBase64(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64(key)
+	default:
+		return nil, errors.New("only RSA, ECDSA and Ed25519 keys can be published")
+	}
+	return jwk, nil
+}
+
+// PublicKey returns the key described by the JSON web key.
+func (k *JSONWebKey) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBase64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, errors.New("invalid EC public key")
+		}
+		return publicKey, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBase64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+// KeySet returns the key set itself, so a fixed set can be published with JWKSHandler.
+func (s *JSONWebKeySet) KeySet() (*JSONWebKeySet, error) {
+	return s, nil
+}
+
+// Key returns the key with the given key id.
+func (s *JSONWebKeySet) Key(kid string) (*JSONWebKey, bool) {
+	for i := range s.Keys {
+		if s.Keys[i].Kid == kid {
+			return &s.Keys[i], true
+		}
+	}
+	return nil, false
+}
+
+// ResolveKey picks the key matching the kid header of the token. Tokens without kid
+// are only accepted when the set holds a single key.
+func (s *JSONWebKeySet) ResolveKey(parseToken *jwt.Token) (interface{}, error) {
+	kid, _ := parseToken.Header["kid"].(string)
+	var jwk *JSONWebKey
+	if kid == "" && len(s.Keys) == 1 {
+		jwk = &s.Keys[0]
+	} else if found, ok := s.Key(kid); ok && kid != "" {
+		jwk = found
+	} else {
+		return nil, errors.Errorf("no key found for kid %q", kid)
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return nil, errors.Errorf("key %q is not a signing key", kid)
+	}
+	if jwk.Alg != "" && jwk.Alg != parseToken.Method.Alg() {
+		return nil, errors.Errorf("key %q is not meant for signing method %s", kid, parseToken.Method.Alg())
+	}
+	return jwk.PublicKey()
+}
+
+// JWKSHandler publishes the key set of the given source as JSON, to be
+// mounted at a path such as /.well-known/jwks.json.
+func JWKSHandler(source KeySetSource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		keySet, err := source.KeySet()
+		if err != nil {
+			http.Error(w, "key set unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(keySet); err != nil {
+			http.Error(w, "key set unavailable", http.StatusInternalServerError)
+		}
+	})
+}
+
+// JWKSResolver resolves keys from a key set loaded from a local file or an
+// HTTP URL, cached and loaded again once the refresh interval passed or a
+// token comes with a kid that is not known yet.
+type JWKSResolver struct {
+	source          string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu        sync.Mutex
+	keySet    *JSONWebKeySet
+	fetchedAt time.Time
+	loadErr   error
+}
+
+// NewJWKSResolver creates a resolver for the key set at the given http(s) URL or file path.
+func NewJWKSResolver(source string, refreshInterval time.Duration) *JWKSResolver {
+	return &JWKSResolver{
+		source:          source,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ResolveKey picks the key matching the kid header of the token.
+func (r *JWKSResolver) ResolveKey(parseToken *jwt.Token) (interface{}, error) {
+	keySet, err := r.KeySet()
+	if err != nil {
+		return nil, err
+	}
+	key, err := keySet.ResolveKey(parseToken)
+	if err == nil {
+		return key, nil
+	}
+
+	// The key might have been added since the set was loaded.
+	r.mu.Lock()
+	stale := time.Since(r.fetchedAt) > minRefetchInterval
+	r.mu.Unlock()
+	if !stale {
+		return nil, err
+	}
+	if keySet, err = r.Refresh(); err != nil {
+		return nil, err
+	}
+	return keySet.ResolveKey(parseToken)
+}
+
+// KeySet returns the cached key set, loading it when it is missing or outdated.
+func (r *JWKSResolver) KeySet() (*JSONWebKeySet, error) {
+	r.mu.Lock()
+	keySet, fetchedAt, loadErr := r.keySet, r.fetchedAt, r.loadErr
+	r.mu.Unlock()
+	if keySet != nil && time.Since(fetchedAt) < r.refreshInterval {
+		return keySet, nil
+	}
+	if keySet == nil && loadErr != nil && time.Since(fetchedAt) < minRefetchInterval {
+		// The source just failed, give it some time before trying again.
+		return nil, loadErr
+	}
+	refreshed, err := r.Refresh()
+	if err != nil && keySet != nil {
+		// Keep going with the previous keys until the source is back.
+		return keySet, nil
+	}
+	return refreshed, err
+}
+
+// Refresh loads the key set from its source.
+func (r *JWKSResolver) Refresh() (*JSONWebKeySet, error) {
+	data, err := r.load()
+	if err == nil {
+		keySet := &JSONWebKeySet{}
+		if err = json.Unmarshal(data, keySet); err == nil {
+			r.mu.Lock()
+			r.keySet, r.fetchedAt, r.loadErr = keySet, time.Now(), nil
+			r.mu.Unlock()
+			return keySet, nil
+		}
+	}
+	err = errors.Wrapf(err, "unable to load key set from %s", r.source)
+	r.mu.Lock()
+	r.fetchedAt, r.loadErr = time.Now(), err
+	r.mu.Unlock()
+	return nil, err
+}
+
+func (r *JWKSResolver) load() ([]byte, error) {
+	if !strings.HasPrefix(r.source, "http://") && !strings.HasPrefix(r.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(r.source, "file://"))
+	}
+	response, err := r.client.Get(r.source)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxKeySetSize))
+}
+
+func encodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBase64(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+}
@@ -0,0 +1,143 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJSONWebKeyRoundTrip(t *testing.T) {
+	for _, signingMethod := range []string{"RS256", "ES256", "EdDSA"} {
+		privateKey, publicKey := generatePEMKeyPair(t, signingMethod)
+		parsedPrivateKey, err := ParseSigningKey(signingMethod, privateKey)
+		if err != nil {
+			t.Fatalf("error parsing %s private key ->> %s", signingMethod, err)
+		}
+		parsedPublicKey, err := ParseVerificationKey(signingMethod, publicKey)
+		if err != nil {
+			t.Fatalf("error parsing %s public key ->> %s", signingMethod, err)
+		}
+
+		jwk, err := NewJSONWebKey("key-1", signingMethod, parsedPrivateKey)
+		if err != nil {
+			t.Fatalf("error creating %s json web key ->> %s", signingMethod, err)
+		}
+		jwkPublicKey, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("error reading %s json web key ->> %s", signingMethod, err)
+		}
+		original, _ := NewJSONWebKey("key-1", signingMethod, parsedPublicKey)
+		restored, _ := NewJSONWebKey("key-1", signingMethod, jwkPublicKey)
+		if *original != *restored {
+			t.Fatalf("\n expected ->> %v\n found ->> %v", original, restored)
+		}
+	}
+}
+
+func TestJWKSHandlerAndResolver(t *testing.T) {
+	privateKey, _ := generatePEMKeyPair(t, "RS256")
+	issuer, err := NewIssuer(WithKey(privateKey), WithAlgorithm("RS256"), WithKeyID("2021-09"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	var fetches int32
+	jwksHandler := JWKSHandler(issuer)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		jwksHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	verifier, err := NewVerifier(WithKeyResolver(NewJWKSResolver(server.URL, time.Hour)), WithAlgorithm("RS256"))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		token, err := issuer.Generate(randomMiniClaims())
+		if err != nil {
+			t.Fatalf("error while creating token ->> %s", err)
+		}
+		if _, err := verifier.Parse(token); err != nil {
+			t.Fatalf("error parsing token with key set ->> %s", err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected the key set to be fetched once, fetched %d times", fetches)
+	}
+
+	otherIssuer, _ := NewIssuer(WithKey(privateKey), WithAlgorithm("RS256"), WithKeyID("unknown"))
+	token, err := otherIssuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if verifier.IsValid(token) {
+		t.Fatal("expected token with unknown kid to be invalid")
+	}
+}
+
+func TestJWKSResolverFromFile(t *testing.T) {
+	privateKey, _ := generatePEMKeyPair(t, "EdDSA")
+	issuer, err := NewIssuer(WithKey(privateKey), WithAlgorithm("EdDSA"), WithKeyID("ed"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	keySet, err := issuer.KeySet()
+	if err != nil {
+		t.Fatalf("error reading key set ->> %s", err)
+	}
+	data, _ := json.Marshal(keySet)
+	keySetFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(keySetFile, data, 0600); err != nil {
+		t.Fatalf("error writing key set file ->> %s", err)
+	}
+
+	verifier, err := NewVerifier(WithKeyResolver(NewJWKSResolver(keySetFile, time.Hour)), WithAlgorithm("EdDSA"))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	token, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !verifier.IsValid(token) {
+		t.Fatal("expected token to be valid with the key set file")
+	}
+}
+
+func TestJWKSResolverThrottlesFailedLoads(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	resolver := NewJWKSResolver(server.URL, time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.KeySet(); err == nil {
+			t.Fatal("expected the key set to fail loading")
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected a failed key set to be fetched once, fetched %d times", fetches)
+	}
+}
+
+func TestHMACIssuerHasNoKeySet(t *testing.T) {
+	issuer, _ := NewIssuer(WithKey(tokenKey))
+	keySet, err := issuer.KeySet()
+	if err != nil || len(keySet.Keys) != 0 {
+		t.Fatalf("expected an empty key set for an HMAC secret found %v ->> %v", keySet, err)
+	}
+	recorder := httptest.NewRecorder()
+	JWKSHandler(issuer).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jwks.json", nil))
+	if recorder.Code != http.StatusOK || strings.TrimSpace(recorder.Body.String()) != `{"keys":[]}` {
+		t.Fatalf("expected an empty key set found %d %s", recorder.Code, recorder.Body)
+	}
+}
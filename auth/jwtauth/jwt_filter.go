@@ -2,23 +2,63 @@ package jwtauth
 
 import (
 	"net/http"
-	"os"
 
-	"github.com/bellomd/miniauth/auth/authenv"
+	"github.com/dgrijalva/jwt-go"
 )
 
 // DoFilter check if the request has the requeired permission
+// using the default verifier. When the DefaultTokenCookie env lets
+// it read the token from the cookie, state changing requests carrying
+// the cookie must pass the csrf check of CSRFProtect as well.
 func DoFilter(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get(os.Getenv(authenv.AuthorizationHeaderKey))
-		if authHeader == "" {
-			http.Error(w, "invalid token", http.StatusForbidden)
+		verifier, err := DefaultVerifier()
+		if err != nil {
+			http.Error(w, "token verification unavailable", http.StatusInternalServerError)
 			return
 		}
-		if !IsValidDefault(authHeader) {
-			http.Error(w, "invalid token", http.StatusForbidden)
+		filter := verifier.Filter(handler)
+		if defaultTokenCookie() {
+			filter = CSRFProtect(CookieSettings{})(filter)
+		}
+		filter.ServeHTTP(w, r)
+	})
+}
+
+// Filter check if the request has a valid token where the verifier looks for it,
+// the claims of the token are passed on in the request context. Refused requests
+// are answered by the error handler of the verifier, with 401 and the
+// WWW-Authenticate header of RFC 6750 by default.
+func (v *Verifier) Filter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, authErr := v.authenticate(r)
+		if authErr != nil {
+			v.refuse(w, r, authErr)
 			return
 		}
-		handler.ServeHTTP(w, r)
+		handler.ServeHTTP(w, r.WithContext(v.claimsContext(r, claims)))
 	})
 }
+
+// authenticate verifies the token the extractor of the verifier finds in the request.
+func (v *Verifier) authenticate(r *http.Request) (jwt.MapClaims, *AuthError) {
+	token, err := v.extractor.Extract(r)
+	if err == ErrNoToken {
+		return nil, missingTokenError()
+	}
+	if err != nil {
+		v.logFailure(r, "", err)
+		return nil, invalidRequestError(err)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := v.parse(r, token, claims); err != nil {
+		return nil, invalidTokenError(err)
+	}
+	return claims, nil
+}
+
+// refuse answers the request with the error handler of the verifier.
+func (v *Verifier) refuse(w http.ResponseWriter, r *http.Request, authErr *AuthError) {
+	authErr.Realm = v.realm
+	v.errorHandler(w, r, authErr)
+}
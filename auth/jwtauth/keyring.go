@@ -0,0 +1,182 @@
+package jwtauth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// Keyring signs with its current key and keeps verifying tokens signed with
+// retired keys until their grace period ends, so rotating the key does not
+// invalidate every issued token at once. It is safe for concurrent use.
+type Keyring struct {
+	gracePeriod time.Duration
+
+	mu      sync.RWMutex
+	current *ringKey
+	retired []*ringKey
+}
+
+type ringKey struct {
+	id              string
+	method          jwt.SigningMethod
+	signingKey      interface{}
+	verificationKey interface{}
+	retiredUntil    time.Time
+}
+
+// NewKeyring creates a keyring signing with the given key. Retired keys stay
+// valid for verification during the grace period, which should be at least
+// as long as the lifetime of the tokens.
+func NewKeyring(kid string, signingMethod string, tokenKey []byte, gracePeriod time.Duration) (*Keyring, error) {
+	current, err := newRingKey(kid, signingMethod, tokenKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{gracePeriod: gracePeriod, current: current}, nil
+}
+
+func newRingKey(kid string, signingMethod string, tokenKey []byte) (*ringKey, error) {
+	if kid == "" {
+		return nil, errors.New("invalid key id")
+	}
+	method := jwt.GetSigningMethod(signingMethod)
+	if method == nil {
+		return nil, errors.New("invalid signing method")
+	}
+	signingKey, err := ParseSigningKey(signingMethod, tokenKey)
+	if err != nil {
+		return nil, err
+	}
+	verificationKey, err := ParseVerificationKey(signingMethod, tokenKey)
+	if err != nil {
+		return nil, err
+	}
+	return &ringKey{id: kid, method: method, signingKey: signingKey, verificationKey: verificationKey}, nil
+}
+
+// Rotate makes the given key the current one and retires the previous current
+// key, which keeps verifying tokens until the grace period ends.
+func (k *Keyring) Rotate(kid string, signingMethod string, tokenKey []byte) error {
+	next, err := newRingKey(kid, signingMethod, tokenKey)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, found := k.lookup(kid); found {
+		return errors.Errorf("key id %q is already in use", kid)
+	}
+	k.current.retiredUntil = time.Now().Add(k.gracePeriod)
+	k.retired = append(k.pruned(), k.current)
+	k.current = next
+	return nil
+}
+
+// Retire drops the retired key with the given key id before its grace period ends,
+// for example when it was compromised. The current key cannot be retired this way.
+func (k *Keyring) Retire(kid string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	retired := make([]*ringKey, 0, len(k.retired))
+	for _, key := range k.pruned() {
+		if key.id != kid {
+			retired = append(retired, key)
+		}
+	}
+	k.retired = retired
+}
+
+// CurrentKeyID returns the key id tokens are signed with right now.
+func (k *Keyring) CurrentKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current.id
+}
+
+// signing returns the current key along with its signing method and key id.
+func (k *Keyring) signing() (kid string, method jwt.SigningMethod, signingKey interface{}) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current.id, k.current.method, k.current.signingKey
+}
+
+// ResolveKey returns the verification key matching the kid header of the token,
+// tokens without kid are verified with the current key.
+func (k *Keyring) ResolveKey(parseToken *jwt.Token) (interface{}, error) {
+	kid, _ := parseToken.Header["kid"].(string)
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key := k.current
+	if kid != "" {
+		var found bool
+		if key, found = k.lookup(kid); !found {
+			return nil, errors.Errorf("no key found for kid %q", kid)
+		}
+	}
+	if key.method.Alg() != parseToken.Method.Alg() {
+		return nil, &AlgorithmError{Algorithm: parseToken.Method.Alg()}
+	}
+	return key.verificationKey, nil
+}
+
+// KeySet returns the public keys of the current and retired keys, HMAC secrets are left out.
+func (k *Keyring) KeySet() (*JSONWebKeySet, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keySet := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range append([]*ringKey{k.current}, k.retired...) {
+		if !key.retiredUntil.IsZero() && time.Now().After(key.retiredUntil) {
+			continue
+		}
+		if !IsAsymmetric(key.method.Alg()) {
+			continue
+		}
+		jwk, err := NewJSONWebKey(key.id, key.method.Alg(), key.verificationKey)
+		if err != nil {
+			return nil, err
+		}
+		keySet.Keys = append(keySet.Keys, *jwk)
+	}
+	return keySet, nil
+}
+
+// allows reports whether the current or a retired key is used with the given signing method.
+func (k *Keyring) allows(signingMethod string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range append([]*ringKey{k.current}, k.pruned()...) {
+		if key.method.Alg() == signingMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup finds the current or a retired key whose grace period did not end yet.
+func (k *Keyring) lookup(kid string) (*ringKey, bool) {
+	if k.current.id == kid {
+		return k.current, true
+	}
+	now := time.Now()
+	for _, key := range k.retired {
+		if key.id == kid && now.Before(key.retiredUntil) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// pruned returns the retired keys whose grace period did not end yet.
+func (k *Keyring) pruned() []*ringKey {
+	now := time.Now()
+	retired := make([]*ringKey, 0, len(k.retired))
+	for _, key := range k.retired {
+		if now.Before(key.retiredUntil) {
+			retired = append(retired, key)
+		}
+	}
+	return retired
+}
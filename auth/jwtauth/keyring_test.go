@@ -0,0 +1,155 @@
+package jwtauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	keyring, err := NewKeyring("2021-08", "HS512", []byte("first-secret"), time.Hour)
+	if err != nil {
+		t.Fatalf("error creating keyring ->> %s", err)
+	}
+	issuer, err := NewIssuer(WithKeyring(keyring))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	verifier, err := NewVerifier(WithKeyring(keyring))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	oldToken, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	if err := keyring.Rotate("2021-09", "HS512", []byte("second-secret")); err != nil {
+		t.Fatalf("error rotating key ->> %s", err)
+	}
+	newToken, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !verifier.IsValid(oldToken) {
+		t.Fatal("expected token of the retired key to be valid during the grace period")
+	}
+	if !verifier.IsValid(newToken) {
+		t.Fatal("expected token of the current key to be valid")
+	}
+	if IsValid("Bearer "+newToken, []byte("first-secret")) {
+		t.Fatal("expected the new token to be signed with the new key")
+	}
+
+	keyring.Retire("2021-08")
+	if verifier.IsValid(oldToken) {
+		t.Fatal("expected token of the removed key to be invalid")
+	}
+}
+
+func TestKeyringGracePeriod(t *testing.T) {
+	keyring, err := NewKeyring("old", "HS256", []byte("first-secret"), -time.Second)
+	if err != nil {
+		t.Fatalf("error creating keyring ->> %s", err)
+	}
+	issuer, _ := NewIssuer(WithKeyring(keyring))
+	token, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if err := keyring.Rotate("new", "HS256", []byte("second-secret")); err != nil {
+		t.Fatalf("error rotating key ->> %s", err)
+	}
+	if issuer.Verifier().IsValid(token) {
+		t.Fatal("expected token to be invalid once the grace period ended")
+	}
+	if err := keyring.Rotate("new", "HS256", []byte("third-secret")); err == nil {
+		t.Fatal("expected error reusing a key id")
+	}
+}
+
+func TestKeyringKeySet(t *testing.T) {
+	firstKey, _ := generatePEMKeyPair(t, "ES256")
+	secondKey, _ := generatePEMKeyPair(t, "ES256")
+	keyring, err := NewKeyring("first", "ES256", firstKey, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating keyring ->> %s", err)
+	}
+	if err := keyring.Rotate("second", "ES256", secondKey); err != nil {
+		t.Fatalf("error rotating key ->> %s", err)
+	}
+	keySet, err := keyring.KeySet()
+	if err != nil {
+		t.Fatalf("error reading key set ->> %s", err)
+	}
+	if len(keySet.Keys) != 2 {
+		t.Fatalf("expected 2 published keys found %d", len(keySet.Keys))
+	}
+	if _, ok := keySet.Key("first"); !ok {
+		t.Fatal("expected the retired key to be published")
+	}
+}
+
+func TestKeyringConcurrentRotation(t *testing.T) {
+	keyring, err := NewKeyring("key-0", "HS256", []byte("secret-0"), time.Hour)
+	if err != nil {
+		t.Fatalf("error creating keyring ->> %s", err)
+	}
+	issuer, _ := NewIssuer(WithKeyring(keyring))
+	handler := issuer.Verifier().Filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			keyring.Rotate(fmt.Sprintf("key-%d", i), "HS256", []byte(fmt.Sprintf("secret-%d", i)))
+		}(i)
+		go func() {
+			defer wg.Done()
+			token, _ := issuer.Generate(randomMiniClaims())
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusOK {
+				t.Errorf("expected %d found %d", http.StatusOK, recorder.Code)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestKeyringAllowedAlgorithms(t *testing.T) {
+	keyring, err := NewKeyring("hs512", "HS512", []byte("first-secret"), time.Hour)
+	if err != nil {
+		t.Fatalf("error creating keyring ->> %s", err)
+	}
+	issuer, _ := NewIssuer(WithKeyring(keyring))
+	verifier, _ := NewVerifier(WithKeyring(keyring))
+	oldToken, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if err := keyring.Rotate("hs256", "HS256", []byte("second-secret")); err != nil {
+		t.Fatalf("error rotating key ->> %s", err)
+	}
+	newToken, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !verifier.IsValid(oldToken) || !verifier.IsValid(newToken) {
+		t.Fatal("expected the tokens of both keys to be valid")
+	}
+	if !verifier.Allows("HS256") || verifier.Allows("RS256") {
+		t.Fatal("expected the verifier to allow the signing methods of the keyring only")
+	}
+
+	restricted, _ := NewVerifier(WithKeyring(keyring), WithAllowedAlgorithms("HS512"))
+	if restricted.IsValid(newToken) {
+		t.Fatal("expected the allowed algorithms to take precedence over the keyring")
+	}
+}
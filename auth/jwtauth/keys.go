@@ -0,0 +1,165 @@
+package jwtauth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// IsAsymmetric reports whether the given signing method signs with a private
+// key and verifies with the matching public key.
+func IsAsymmetric(signingMethod string) bool {
+	switch jwt.GetSigningMethod(signingMethod).(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *SigningMethodEd25519:
+		return true
+	}
+	return false
+}
+
+// ParseSigningKey converts the given key to the type the signing method signs with.
+// HMAC methods use the key as the secret, every other method expects a PEM encoded
+// private key (PKCS1, PKCS8 or SEC1).
+func ParseSigningKey(signingMethod string, tokenKey []byte) (interface{}, error) {
+	if len(tokenKey) == 0 {
+		return nil, errors.New("invalid key")
+	}
+	switch method := jwt.GetSigningMethod(signingMethod).(type) {
+	case *jwt.SigningMethodHMAC:
+		return hmacSecret(tokenKey)
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		return jwt.ParseRSAPrivateKeyFromPEM(tokenKey)
+	case *jwt.SigningMethodECDSA:
+		privateKey, err := parseECPrivateKey(tokenKey)
+		if err != nil {
+			return nil, err
+		}
+		if privateKey.Curve.Params().BitSize != method.CurveBits {
+			return nil, errors.Errorf("key curve does not match signing method %s", signingMethod)
+		}
+		return privateKey, nil
+	case *SigningMethodEd25519:
+		return parseEd25519PrivateKey(tokenKey)
+	case nil:
+		return nil, errors.New("invalid signing method")
+	default:
+		return nil, errors.Errorf("unsupported signing method %s", signingMethod)
+	}
+}
+
+// ParseVerificationKey converts the given key to the type the signing method verifies with.
+// HMAC methods use the key as the secret, every other method expects a PEM encoded public
+// key or certificate. A PEM encoded private key is accepted as well, its public half is used.
+func ParseVerificationKey(signingMethod string, tokenKey []byte) (interface{}, error) {
+	if len(tokenKey) == 0 {
+		return nil, errors.New("invalid key")
+	}
+	switch jwt.GetSigningMethod(signingMethod).(type) {
+	case *jwt.SigningMethodHMAC:
+		return hmacSecret(tokenKey)
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if publicKey, err := jwt.ParseRSAPublicKeyFromPEM(tokenKey); err == nil {
+			return publicKey, nil
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(tokenKey)
+		if err != nil {
+			return nil, jwt.ErrNotRSAPublicKey
+		}
+		return &privateKey.PublicKey, nil
+	case *jwt.SigningMethodECDSA:
+		if publicKey, err := jwt.ParseECPublicKeyFromPEM(tokenKey); err == nil {
+			return publicKey, nil
+		}
+		privateKey, err := parseECPrivateKey(tokenKey)
+		if err != nil {
+			return nil, jwt.ErrNotECPublicKey
+		}
+		return &privateKey.PublicKey, nil
+	case *SigningMethodEd25519:
+		if publicKey, err := parseEd25519PublicKey(tokenKey); err == nil {
+			return publicKey, nil
+		}
+		privateKey, err := parseEd25519PrivateKey(tokenKey)
+		if err != nil {
+			return nil, errors.New("key is not a valid Ed25519 public key")
+		}
+		return privateKey.Public(), nil
+	case nil:
+		return nil, errors.New("invalid signing method")
+	default:
+		return nil, errors.Errorf("unsupported signing method %s", signingMethod)
+	}
+}
+
+// hmacSecret refuses PEM encoded keys as HMAC secret, otherwise a public key
+// known to everybody could be used to forge a token signed with HS256.
+func hmacSecret(tokenKey []byte) ([]byte, error) {
+	if isPEM(tokenKey) {
+		return nil, errors.New("PEM encoded key cannot be used as HMAC secret")
+	}
+	return tokenKey, nil
+}
+
+func isPEM(key []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN "))
+}
+
+// parseECPrivateKey accepts PKCS8 on top of the SEC1 keys jwt-go understands.
+func parseECPrivateKey(key []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+	if privateKey, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return privateKey, nil
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := parsedKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, jwt.ErrNotECPrivateKey
+	}
+	return privateKey, nil
+}
+
+func parseEd25519PrivateKey(key []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := parsedKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not a valid Ed25519 private key")
+	}
+	return privateKey, nil
+}
+
+func parseEd25519PublicKey(key []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+	parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		cert, certErr := x509.ParseCertificate(block.Bytes)
+		if certErr != nil {
+			return nil, err
+		}
+		parsedKey = cert.PublicKey
+	}
+	publicKey, ok := parsedKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("key is not a valid Ed25519 public key")
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,117 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+)
+
+func TestAsymmetricTokens(t *testing.T) {
+	for _, signingMethod := range []string{"RS256", "PS256", "ES256", "EdDSA"} {
+		t.Setenv(authenv.SigningMethodEnvKey, signingMethod)
+		privateKey, publicKey := generatePEMKeyPair(t, signingMethod)
+		token, err := Generate(signingMethod, randomMiniClaims(), privateKey)
+		if err != nil {
+			t.Fatalf("error while creating %s token ->> %s", signingMethod, err)
+		}
+
+		parsedClaims := &MiniClaims{}
+		err = ParseTokenWithClaims(fmt.Sprintf("Bearer %s", token), parsedClaims, publicKey)
+		if err != nil {
+			t.Fatalf("error parsing %s token with public key ->> %s", signingMethod, err)
+		}
+		if !IsValid(fmt.Sprintf("Bearer %s", token), publicKey) {
+			t.Fatalf("expected %s token to be valid with public key", signingMethod)
+		}
+	}
+}
+
+func TestAsymmetricTokenWithWrongKey(t *testing.T) {
+	t.Setenv(authenv.SigningMethodEnvKey, "ES256")
+	privateKey, _ := generatePEMKeyPair(t, "ES256")
+	_, otherPublicKey := generatePEMKeyPair(t, "ES256")
+	token, err := Generate("ES256", randomMiniClaims(), privateKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if IsValid(fmt.Sprintf("Bearer %s", token), otherPublicKey) {
+		t.Fatal("expected token to be invalid with another public key")
+	}
+}
+
+func TestPublicKeyAsHMACSecret(t *testing.T) {
+	_, publicKey := generatePEMKeyPair(t, "RS256")
+
+	// A token signed with the public key as HMAC secret must not verify.
+	_, err := Generate("HS256", randomMiniClaims(), publicKey)
+	if err == nil {
+		t.Fatal("expected error signing with a PEM key as HMAC secret")
+	}
+	if _, err := ParseVerificationKey("HS256", publicKey); err == nil {
+		t.Fatal("expected error verifying with a PEM key as HMAC secret")
+	}
+}
+
+func TestAsymmetricTokenWithDefault(t *testing.T) {
+	privateKey, publicKey := generatePEMKeyPair(t, "RS256")
+	keyFile := filepath.Join(t.TempDir(), "private.pem")
+	if err := os.WriteFile(keyFile, privateKey, 0600); err != nil {
+		t.Fatalf("error writing key file ->> %s", err)
+	}
+	t.Setenv(authenv.SigningMethodEnvKey, "RS256")
+	t.Setenv(authenv.PrivateKeyFileEnvKey, keyFile)
+	t.Setenv(authenv.PublicKeyEnvKey, strings.ReplaceAll(string(publicKey), "\n", `\n`))
+
+	token, err := GenerateWithDefault(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !IsValidDefault(fmt.Sprintf("Bearer %s", token)) {
+		t.Fatal("expected token to be valid with the default public key")
+	}
+	if !IsValid(fmt.Sprintf("Bearer %s", token), publicKey) {
+		t.Fatal("expected token to be valid with the public key")
+	}
+}
+
+func generatePEMKeyPair(t *testing.T, signingMethod string) (privatePEM []byte, publicPEM []byte) {
+	var privateKey, publicKey interface{}
+	var err error
+	switch signingMethod {
+	case "RS256", "PS256":
+		var key *rsa.PrivateKey
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		privateKey, publicKey = key, &key.PublicKey
+	case "ES256":
+		var key *ecdsa.PrivateKey
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		privateKey, publicKey = key, &key.PublicKey
+	case "EdDSA":
+		publicKey, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("error generating %s key ->> %s", signingMethod, err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("error encoding private key ->> %s", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("error encoding public key ->> %s", err)
+	}
+	privatePEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	return privatePEM, publicPEM
+}
@@ -0,0 +1,144 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// requestIDHeader is where the request id of failure events is read from.
+const requestIDHeader = "X-Request-Id"
+
+// Default rate of failure events, so a flood of bad tokens cannot flood the logs as well.
+const (
+	defaultLogRate     = 10
+	defaultLogInterval = time.Second
+)
+
+// EventLogger receives the failures a Verifier runs into as structured events, with the
+// message followed by key value pairs. *slog.Logger satisfies it.
+type EventLogger interface {
+	Warn(msg string, args ...interface{})
+}
+
+// logLimiter lets through a number of events per interval and counts the ones it dropped.
+type logLimiter struct {
+	rate     int
+	interval time.Duration
+
+	mu      sync.Mutex
+	start   time.Time
+	count   int
+	dropped int
+}
+
+func newLogLimiter(rate int, interval time.Duration) *logLimiter {
+	return &logLimiter{rate: rate, interval: interval}
+}
+
+// allow reports whether an event may be logged now, along with the number of events
+// dropped since the last one that was.
+func (l *logLimiter) allow() (bool, int) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.start) >= l.interval {
+		l.start, l.count = now, 0
+	}
+	if l.count >= l.rate {
+		l.dropped++
+		return false, 0
+	}
+	l.count++
+	dropped := l.dropped
+	l.dropped = 0
+	return true, dropped
+}
+
+// logFailure reports why the given token was rejected, the request is nil outside of filters.
+// The subject is read from the unverified payload, it tells what the token claimed to be.
+func (v *Verifier) logFailure(r *http.Request, token string, err error) {
+	if v.events == nil && v.logger == nil {
+		return
+	}
+	allowed, dropped := v.limiter.allow()
+	if !allowed {
+		return
+	}
+	if v.logger != nil {
+		v.logger.Printf("error parsing token ->> %s", err)
+	}
+	if v.events == nil {
+		return
+	}
+	args := []interface{}{"reason", failureReason(err), "error", err.Error()}
+	header, payload := unverifiedParts(token)
+	if alg, _ := header["alg"].(string); alg != "" {
+		args = append(args, "alg", alg)
+	}
+	if kid, _ := header["kid"].(string); kid != "" {
+		args = append(args, "kid", kid)
+	}
+	if sub, _ := payload["sub"].(string); sub != "" {
+		args = append(args, "sub", sub)
+	}
+	if r != nil {
+		if requestID := r.Header.Get(requestIDHeader); requestID != "" {
+			args = append(args, "request_id", requestID)
+		}
+	}
+	if dropped > 0 {
+		args = append(args, "dropped", dropped)
+	}
+	v.events.Warn("token rejected", args...)
+}
+
+// failureReason names the reason of a failure in a word that can be filtered on.
+func failureReason(err error) string {
+	reasons := []struct {
+		err    error
+		reason string
+	}{
+		{ErrNoToken, "no_token"},
+		{ErrMalformed, "malformed"},
+		{ErrTokenExpired, "expired"},
+		{ErrTokenNotYetValid, "not_yet_valid"},
+		{ErrSignatureInvalid, "signature_invalid"},
+		{ErrAlgorithmNotAllowed, "algorithm_not_allowed"},
+		{ErrMissingKey, "missing_key"},
+		{ErrRevoked, "revoked"},
+		{ErrUnknownTenant, "unknown_tenant"},
+		{ErrIssuerMismatch, "issuer_mismatch"},
+		{ErrAudienceMismatch, "audience_mismatch"},
+		{ErrMissingClaim, "missing_claim"},
+		{ErrInvalidClaim, "invalid_claim"},
+	}
+	for _, candidate := range reasons {
+		if errors.Is(err, candidate.err) {
+			return candidate.reason
+		}
+	}
+	return "invalid"
+}
+
+// unverifiedParts decodes the header and payload of a token without verifying it,
+// whatever cannot be decoded is left empty.
+func unverifiedParts(token string) (header map[string]interface{}, payload map[string]interface{}) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil
+	}
+	if segment, err := jwt.DecodeSegment(parts[0]); err == nil {
+		json.Unmarshal(segment, &header)
+	}
+	payload, _ = decodePayload(token)
+	return header, payload
+}
@@ -0,0 +1,77 @@
+package jwtauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type recordedEvent struct {
+	msg    string
+	fields map[string]interface{}
+}
+
+type eventRecorder struct {
+	events []recordedEvent
+}
+
+func (r *eventRecorder) Warn(msg string, args ...interface{}) {
+	fields := map[string]interface{}{}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = args[i+1]
+	}
+	r.events = append(r.events, recordedEvent{msg: msg, fields: fields})
+}
+
+func TestFilterLogsFailureEvent(t *testing.T) {
+	recorder := &eventRecorder{}
+	issuer, err := NewIssuer(WithKey(tokenKey), WithKeyID("key-1"), WithEventLogger(recorder))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	token, err := issuer.Generate(jwt.MapClaims{"sub": "someone", "exp": time.Now().Add(-time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("X-Request-Id", "request-1")
+	issuer.Verifier().Filter(okHandler()).ServeHTTP(httptest.NewRecorder(), request)
+
+	if len(recorder.events) != 1 {
+		t.Fatalf("expected 1 event found %d", len(recorder.events))
+	}
+	expected := map[string]interface{}{"reason": "expired", "alg": "HS512", "kid": "key-1", "sub": "someone", "request_id": "request-1"}
+	for key, value := range expected {
+		if recorder.events[0].fields[key] != value {
+			t.Fatalf("expected %s to be %v found %v", key, value, recorder.events[0].fields[key])
+		}
+	}
+}
+
+func TestLogRateLimit(t *testing.T) {
+	recorder := &eventRecorder{}
+	verifier, err := NewVerifier(WithKey(tokenKey), WithEventLogger(recorder), WithLogRateLimit(2, time.Hour))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	for i := 0; i < 5; i++ {
+		verifier.IsValid("not.a.token")
+	}
+	if len(recorder.events) != 2 {
+		t.Fatalf("expected 2 events found %d", len(recorder.events))
+	}
+	if reason := recorder.events[0].fields["reason"]; reason != "malformed" {
+		t.Fatalf("expected reason malformed found %v", reason)
+	}
+
+	verifier.limiter.start = time.Time{}
+	verifier.IsValid("not.a.token")
+	if dropped := recorder.events[2].fields["dropped"]; dropped != 3 {
+		t.Fatalf("expected 3 dropped events found %v", dropped)
+	}
+}
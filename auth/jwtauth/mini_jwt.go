@@ -1,9 +1,10 @@
 package jwtauth
 
 import (
-	"fmt"
-	"log"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bellomd/miniauth/auth/authenv"
@@ -25,7 +26,8 @@ func init() {
 	authenv.LoadEnvironmentVariables()
 }
 
-// Generate with the given key, claims and signing method
+// Generate with the given key, claims and signing method. Claims are stamped
+// like GenerateWithDefault does, exp after the ExpirationTime env.
 func Generate(signingMethod string, claims jwt.Claims, tokenKey []byte) (token string, err error) {
 	if claims == nil {
 		return "", errors.New("invalid claims")
@@ -36,11 +38,22 @@ func Generate(signingMethod string, claims jwt.Claims, tokenKey []byte) (token s
 	if string(tokenKey) == "" {
 		return "", errors.New("invalid key")
 	}
-	return generateToken(jwt.GetSigningMethod(signingMethod), claims, tokenKey)
+	expiry, err := authenv.LoadExpirationTime()
+	if err != nil {
+		return "", err
+	}
+	issuer, err := NewIssuer(WithAlgorithm(signingMethod), WithKey(tokenKey), WithExpiry(expiry))
+	if err != nil {
+		return "", err
+	}
+	return issuer.Generate(claims)
 }
 
-func generateToken(signingMethod jwt.SigningMethod, claims jwt.Claims, tokenKey []byte) (token string, err error) {
+func generateToken(signingMethod jwt.SigningMethod, claims jwt.Claims, tokenKey interface{}, kid string) (token string, err error) {
 	generatedToken := jwt.NewWithClaims(signingMethod, claims)
+	if kid != "" {
+		generatedToken.Header["kid"] = kid
+	}
 	tokenString, err := generatedToken.SignedString(tokenKey)
 	if err != nil {
 		return "", err
@@ -50,182 +63,294 @@ func generateToken(signingMethod jwt.SigningMethod, claims jwt.Claims, tokenKey
 
 // GenerateWithDefault generate with the signing method and key that was
 // set in the os environment variables, refer to init(...) above on
-// what and how the environment variables are set.
+// what and how the environment variables are set. Claims without jti get
+// a unique one, and claims without iat, nbf or exp get them relative to now,
+// exp after the ExpirationTime env.
 func GenerateWithDefault(claims jwt.Claims) (token string, err error) {
 	if claims == nil {
 		return "", errors.New("invalid claims")
 	}
-	signingMethod := jwt.GetSigningMethod(os.Getenv(authenv.SigningMethodEnvKey))
-	generatedToken := jwt.NewWithClaims(signingMethod, claims)
-	tokenKey := os.Getenv(authenv.TokenEnvKey)
-	tokenString, err := generatedToken.SignedString([]byte(tokenKey))
+	issuer, err := DefaultIssuer()
 	if err != nil {
 		return "", err
 	}
-	return tokenString, nil
+	return issuer.Generate(claims)
 }
 
-// ParseToken parse the given header value to a claim using the given key
-func ParseToken(headerValue string, tokenKey []byte) (claims jwt.Claims, err error) {
-	headerToken := headerValue[7:]
-	parseToken, err := jwt.Parse(headerToken, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
+// GenerateWithExpiry generate like GenerateWithDefault, with a token lifetime
+// of the given expiry in place of the one in the os environment variables.
+func GenerateWithExpiry(claims jwt.Claims, expiry time.Duration) (token string, err error) {
+	if claims == nil {
+		return "", errors.New("invalid claims")
+	}
+	issuer, err := DefaultIssuer()
+	if err != nil {
+		return "", err
+	}
+	return issuer.GenerateWithExpiry(claims, expiry)
+}
+
+// ParseToken parse the given header value to a claim using the given key.
+// Like every function here taking a header value it accepts "Bearer <token>"
+// as well as the raw token, and returns ErrMalformed for anything else.
+// Options such as WithAudiences or WithLeeway tune the validation.
+func ParseToken(headerValue string, tokenKey []byte, opts ...Option) (claims jwt.Claims, err error) {
+	token, err := headerToken(headerValue)
 	if err != nil {
-		log.Printf("error parsing token ->> %s", err)
 		return nil, err
 	}
-	if !parseToken.Valid {
-		return nil, fmt.Errorf("invalid token")
+	verifier, err := NewVerifier(append([]Option{WithKey(tokenKey)}, opts...)...)
+	if err != nil {
+		return nil, err
 	}
-	return parseToken.Claims, nil
+	return verifier.Parse(token)
 }
 
 // ParseTokenWithClaims parse the given header value to the given claim using the given key
-func ParseTokenWithClaims(headerValue string, claims jwt.Claims, tokenKey []byte) (err error) {
-	headerToken := headerValue[7:]
-	parseToken, err := jwt.ParseWithClaims(headerToken, claims, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
+func ParseTokenWithClaims(headerValue string, claims jwt.Claims, tokenKey []byte, opts ...Option) (err error) {
+	token, err := headerToken(headerValue)
 	if err != nil {
-		log.Printf("error parsing token ->> %s", err)
 		return err
 	}
-	if !parseToken.Valid {
-		return fmt.Errorf("invalid token")
+	verifier, err := NewVerifier(append([]Option{WithKey(tokenKey)}, opts...)...)
+	if err != nil {
+		return err
 	}
-	return nil
+	return verifier.ParseWithClaims(token, claims)
 }
 
 // ParseTokenDefault parse the given header value to a claim using the key in the os env.
 func ParseTokenDefault(headerValue string) (claims jwt.Claims, err error) {
-	headerToken := headerValue[7:]
-	tokenKey := []byte(os.Getenv(authenv.TokenEnvKey))
-	parseToken, err := jwt.Parse(headerToken, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
+	token, err := headerToken(headerValue)
 	if err != nil {
-		log.Printf("error parsing token ->> %s", err)
 		return nil, err
 	}
-	if !parseToken.Valid {
-		return nil, fmt.Errorf("invalid token")
+	verifier, err := DefaultVerifier()
+	if err != nil {
+		return nil, err
 	}
-	return parseToken.Claims, nil
+	return verifier.Parse(token)
 }
 
 // ParseTokenWithClaimsDefault parse the given header value to the given claim using the key in the os env.
 func ParseTokenWithClaimsDefault(headerValue string, claims jwt.Claims) (err error) {
-	headerToken := headerValue[7:]
-	tokenKey := []byte(os.Getenv(authenv.TokenEnvKey))
-	parseToken, err := jwt.ParseWithClaims(headerToken, claims, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
+	token, err := headerToken(headerValue)
 	if err != nil {
-		log.Printf("error parsing token ->> %s", err)
 		return err
 	}
-	if !parseToken.Valid {
-		return fmt.Errorf("invalid token")
+	verifier, err := DefaultVerifier()
+	if err != nil {
+		return err
 	}
-	return nil
+	return verifier.ParseWithClaims(token, claims)
 }
 
-// RefreshToken reset the given token expiration time for the given key to future time
-func RefreshToken(token string, tokenKey []byte) (newToken string, err error) {
-	pureToken := token[7:]
-	parseToken, err := jwt.Parse(pureToken, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
+// RefreshToken reset the given token expiration time for the given key to future time,
+// unless it has more than an hour left or the refresh window given with WithRefreshWindow.
+// Tokens lacking exp fail with ErrMissingClaim,
+// for long lived sessions prefer the rotating refresh tokens of RefreshManager.
+func RefreshToken(token string, tokenKey []byte, opts ...Option) (newToken string, err error) {
+	pureToken, err := headerToken(token)
 	if err != nil {
 		return "", err
 	}
-	if !parseToken.Valid {
-		return "", fmt.Errorf("invalid token")
-	}
-
-	// Unless the token is about to expire before renewing it, otherwise,
-	// just return the token to user, to avoid unnecessary creation of token.
-	mapClaims := parseToken.Claims.(jwt.MapClaims)
-	expirationTime := int64(mapClaims["exp"].(float64))
-	if time.Until(time.Unix(expirationTime, 0)) > 1*time.Hour {
-		return pureToken, nil
-	}
-
-	// The token is about to expire, creat a new token for the user
-	mapClaims["exp"] = authenv.DefaultExpirationTime.Unix() // reset the expiration time
-	parseToken.Claims = mapClaims                           // assign the claims back
-	tokenString, err := parseToken.SignedString(tokenKey)   // generate new token
+	issuer, err := NewIssuer(append([]Option{WithKey(tokenKey)}, opts...)...)
 	if err != nil {
 		return "", err
 	}
-	return tokenString, nil
+	return issuer.Refresh(pureToken)
 }
 
 // RefreshWithDefault reset the given token expiration time for the given key to future time
 func RefreshWithDefault(token string) (newToken string, err error) {
-	pureToken := token[7:]
-	tokenKeyStr := os.Getenv(authenv.TokenEnvKey)
-	if tokenKeyStr == "" {
-		return "", fmt.Errorf("invalid key")
-	}
-	tokenKey := []byte(tokenKeyStr)
-	parseToken, err := jwt.Parse(pureToken, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
+	pureToken, err := headerToken(token)
 	if err != nil {
 		return "", err
 	}
-	if !parseToken.Valid {
-		return "", fmt.Errorf("invalid token")
+	issuer, err := DefaultIssuer()
+	if err != nil {
+		return "", err
 	}
+	return issuer.Refresh(pureToken)
+}
 
-	// Unless the token is about to expire before renewing it, otherwise,
-	// just return the token to user, to avoid unnecessary creation of token.
-	mapClaims := parseToken.Claims.(jwt.MapClaims)
-	expirationTime := int64(mapClaims["exp"].(float64))
-	if time.Until(time.Unix(expirationTime, 0)) > 1*time.Hour {
-		return pureToken, nil
+// Validate checks the given header value with the given key and returns why the
+// token is not valid, see Verifier.Validate for the errors it matches.
+func Validate(headerValue string, tokenKey []byte, opts ...Option) error {
+	token, err := headerToken(headerValue)
+	if err != nil {
+		return err
 	}
-
-	// The token is about to expire, creat a new token for the user
-	mapClaims["exp"] = authenv.DefaultExpirationTime.Unix() // reset the expiration time
-	parseToken.Claims = mapClaims                           // assign the claims back
-	tokenString, err := parseToken.SignedString(tokenKey)   // generate new token
+	verifier, err := NewVerifier(append([]Option{WithKey(tokenKey)}, opts...)...)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return tokenString, nil
+	return verifier.Validate(token)
 }
 
-// IsValid checks if the given token is a valid token
-func IsValid(token string, tokenKey []byte) bool {
-	pureToken := token[7:]
-	parseToken, err := jwt.Parse(pureToken, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
+// ValidateDefault checks the given header value with the key in the os env and
+// returns why the token is not valid, see Verifier.Validate for the errors it matches.
+func ValidateDefault(headerValue string) error {
+	token, err := headerToken(headerValue)
+	if err != nil {
+		return err
+	}
+	verifier, err := DefaultVerifier()
 	if err != nil {
-		log.Printf("error parsing token ->> %s", err)
-		return false
+		return err
 	}
-	return parseToken.Valid
+	return verifier.Validate(token)
+}
+
+// IsValid checks if the given token is a valid token
+func IsValid(token string, tokenKey []byte, opts ...Option) bool {
+	return Validate(token, tokenKey, opts...) == nil
 }
 
 // IsValidDefault checks if the given token is a valid token with the key in os env.
 func IsValidDefault(token string) bool {
-	pureToken := token[7:]
-	tokenKeyStr := os.Getenv(authenv.TokenEnvKey)
-	if tokenKeyStr == "" {
-		log.Println("invalid key")
-		return false
+	return ValidateDefault(token) == nil
+}
+
+// defaultJWKSRefreshInterval is how long the default verifier caches the key set of JWKSURLEnvKey.
+const defaultJWKSRefreshInterval = 15 * time.Minute
+
+var defaults struct {
+	sync.Mutex
+	fixed       bool
+	env         string
+	issuer      *Issuer
+	issuerErr   error
+	verifier    *Verifier
+	verifierErr error
+}
+
+// DefaultIssuer returns the issuer behind GenerateWithDefault and RefreshWithDefault.
+// Unless one was set with SetDefault it is built from the os environment variables,
+// and rebuilt whenever they change.
+func DefaultIssuer() (*Issuer, error) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	if defaults.fixed {
+		if defaults.issuer == nil {
+			return nil, errors.New("no default issuer set")
+		}
+		return defaults.issuer, nil
 	}
-	tokenKey := []byte(tokenKeyStr)
-	parseToken, err := jwt.Parse(pureToken, func(parseToken *jwt.Token) (interface{}, error) {
-		return tokenKey, nil
-	})
-	if err != nil {
-		log.Printf("error parsing token ->> %s", err)
-		return false
+	loadDefaults()
+	return defaults.issuer, defaults.issuerErr
+}
+
+// DefaultVerifier returns the verifier behind the *Default functions and DoFilter.
+// Unless one was set with SetDefault it is built from the os environment variables,
+// and rebuilt whenever they change.
+func DefaultVerifier() (*Verifier, error) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	if defaults.fixed {
+		return defaults.verifier, nil
+	}
+	loadDefaults()
+	return defaults.verifier, defaults.verifierErr
+}
+
+// SetDefault replaces the default issuer and verifier, from then on the os
+// environment variables are no longer consulted. A nil verifier falls back to
+// the one of the issuer, passing nil for both goes back to the os environment.
+func SetDefault(issuer *Issuer, verifier *Verifier) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	if verifier == nil && issuer != nil {
+		verifier = issuer.Verifier()
+	}
+	defaults.fixed = verifier != nil
+	defaults.env = ""
+	defaults.issuer, defaults.issuerErr = issuer, nil
+	defaults.verifier, defaults.verifierErr = verifier, nil
+}
+
+// defaultTokenCookie reports whether the defaults read the token from the token cookie too.
+func defaultTokenCookie() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(authenv.TokenCookieEnvKey))
+	return enabled
+}
+
+// loadDefaults builds the default issuer and verifier from the os environment
+// variables, unless they did not change since the last time.
+func loadDefaults() {
+	env := strings.Join([]string{
+		os.Getenv(authenv.SigningMethodEnvKey),
+		os.Getenv(authenv.AuthorizationHeaderKey),
+		os.Getenv(authenv.TokenEnvKey),
+		os.Getenv(authenv.PrivateKeyEnvKey),
+		os.Getenv(authenv.PrivateKeyFileEnvKey),
+		os.Getenv(authenv.PublicKeyEnvKey),
+		os.Getenv(authenv.PublicKeyFileEnvKey),
+		os.Getenv(authenv.JWKSURLEnvKey),
+		os.Getenv(authenv.TokenExpirationKey),
+		os.Getenv(authenv.IssuerEnvKey),
+		os.Getenv(authenv.AudienceEnvKey),
+		os.Getenv(authenv.TokenCookieEnvKey),
+	}, "\x00")
+	if defaults.env == env && (defaults.issuer != nil || defaults.verifier != nil) {
+		return
+	}
+	defaults.env = env
+
+	header := os.Getenv(authenv.AuthorizationHeaderKey)
+	if header == "" {
+		header = authenv.AuthorizationHeader
+	}
+	opts := []Option{
+		WithAlgorithm(os.Getenv(authenv.SigningMethodEnvKey)),
+		WithHeader(header),
+	}
+	if defaultTokenCookie() {
+		// Browser front-ends keep the token in the cookie of SetTokenCookie.
+		opts = append(opts, WithExtractor(MultiExtractor(BearerExtractor(header), CookieExtractor(authenv.TokenCookie))))
+	}
+	defaults.issuer, defaults.verifier = nil, nil
+	signingKey, err := defaultSigningKey()
+	var expiry time.Duration
+	if err == nil {
+		expiry, err = authenv.LoadExpirationTime()
+	}
+	if err == nil {
+		defaults.issuer, err = NewIssuer(append(opts, WithKey(signingKey), WithExpiry(expiry))...)
+	}
+	defaults.issuerErr = err
+	if jwksURL := os.Getenv(authenv.JWKSURLEnvKey); jwksURL != "" {
+		resolver := NewJWKSResolver(jwksURL, defaultJWKSRefreshInterval)
+		defaults.verifier, err = NewVerifier(append(opts, WithKeyResolver(resolver))...)
+	} else {
+		var verificationKey []byte
+		if verificationKey, err = defaultVerificationKey(); err == nil {
+			defaults.verifier, err = NewVerifier(append(opts, WithKey(verificationKey))...)
+		}
+	}
+	defaults.verifierErr = err
+}
+
+// defaultSigningKey returns the private key from the os env for asymmetric
+// signing methods and the shared secret for the HMAC ones.
+func defaultSigningKey() ([]byte, error) {
+	if IsAsymmetric(os.Getenv(authenv.SigningMethodEnvKey)) {
+		return authenv.LoadPrivateKey()
+	}
+	tokenKey := os.Getenv(authenv.TokenEnvKey)
+	if tokenKey == "" {
+		return nil, errors.New("invalid key")
+	}
+	return []byte(tokenKey), nil
+}
+
+// defaultVerificationKey returns the public key from the os env for asymmetric
+// signing methods, falling back to the private key when only that one is set.
+func defaultVerificationKey() ([]byte, error) {
+	if IsAsymmetric(os.Getenv(authenv.SigningMethodEnvKey)) {
+		if publicKey, err := authenv.LoadPublicKey(); err == nil {
+			return publicKey, nil
+		}
 	}
-	return parseToken.Valid
+	return defaultSigningKey()
 }
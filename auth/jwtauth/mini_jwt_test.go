@@ -109,6 +109,11 @@ func TestParseTokenWithClaims(t *testing.T) {
 		t.Fatalf("error passing token for mini claim ->> %s", err)
 	}
 	//parsedClaimsData := parsedClaims.(jwt.MapClaims)["Data"].(map[string]interface{})
+	// The jti and nbf the claims left out are stamped on issue.
+	if parsedClaims.Id == "" || parsedClaims.NotBefore < miniClaims.IssuedAt {
+		t.Fatalf("expected jti and nbf to be stamped found %v", parsedClaims)
+	}
+	miniClaims.Id, miniClaims.NotBefore = parsedClaims.Id, parsedClaims.NotBefore
 	if !reflect.DeepEqual(miniClaims, parsedClaims) {
 		t.Fatalf("\n expected ->> %v\n foundiii ->> %v \n", miniClaims, parsedClaims)
 	}
@@ -154,6 +159,11 @@ func TestParseTokenWithClaimsDefault(t *testing.T) {
 		t.Fatalf("error passing token for mini claim ->> %s", err)
 	}
 	//parsedClaimsData := parsedClaims.(jwt.MapClaims)["Data"].(map[string]interface{})
+	// The jti and nbf the claims left out are stamped on issue.
+	if parsedClaims.Id == "" || parsedClaims.NotBefore < miniClaims.IssuedAt {
+		t.Fatalf("expected jti and nbf to be stamped found %v", parsedClaims)
+	}
+	miniClaims.Id, miniClaims.NotBefore = parsedClaims.Id, parsedClaims.NotBefore
 	if !reflect.DeepEqual(miniClaims, parsedClaims) {
 		t.Fatalf("\n expected ->> %v\n foundiii ->> %v \n", miniClaims, parsedClaims)
 	}
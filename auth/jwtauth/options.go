@@ -0,0 +1,287 @@
+package jwtauth
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+)
+
+// defaultRefreshWindow is how close to its expiration a token has to be before Refresh renews it.
+const defaultRefreshWindow = 1 * time.Hour
+
+// Logger receives the failures an Issuer or Verifier runs into as lines of text, *log.Logger
+// satisfies it. EventLogger gets them as structured events instead.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures an Issuer or a Verifier, options that do not apply
+// to the one being built are ignored.
+type Option func(*config)
+
+type config struct {
+	key           []byte
+	keyID         string
+	resolver      KeyResolver
+	keyring       *Keyring
+	algorithm     string
+	allowed       []string
+	header        string
+	extractor     Extractor
+	realm         string
+	errorHandler  ErrorHandler
+	expiry        time.Duration
+	refreshWindow time.Duration
+	maxSession    time.Duration
+	leeway        time.Duration
+	issuers       []string
+	audiences     []string
+	required      []string
+	maxAge        time.Duration
+	tokenUse      string
+	logger        Logger
+	events        EventLogger
+	logRate       int
+	logInterval   time.Duration
+	revocation    RevocationStore
+	tenants       map[string]*Verifier
+	selector      TenantSelector
+}
+
+// defaultAlgorithm returns the signing method in the os env, HS512 when unset.
+func defaultAlgorithm() string {
+	if signingMethod := os.Getenv(authenv.SigningMethodEnvKey); signingMethod != "" {
+		return signingMethod
+	}
+	return authenv.SigningMethod
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		header:        authenv.AuthorizationHeader,
+		errorHandler:  BearerErrorHandler,
+		expiry:        authenv.ExpirationTime * time.Second,
+		refreshWindow: defaultRefreshWindow,
+		logRate:       defaultLogRate,
+		logInterval:   defaultLogInterval,
+		tokenUse:      TokenUseAccess,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.algorithm == "" {
+		c.algorithm = defaultAlgorithm()
+	}
+	if len(c.allowed) == 0 && c.keyring == nil {
+		c.allowed = []string{c.algorithm}
+	}
+	if c.extractor == nil {
+		c.extractor = BearerExtractor(c.header)
+	}
+	if c.issuers == nil {
+		c.issuers = envList(authenv.IssuerEnvKey)
+	}
+	if c.audiences == nil {
+		c.audiences = envList(authenv.AudienceEnvKey)
+	}
+	return c
+}
+
+// envList returns the comma separated values of the given os env.
+func envList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// WithKey sets the key tokens are signed and verified with, the secret for
+// HMAC signing methods or a PEM encoded key for the asymmetric ones.
+func WithKey(key []byte) Option {
+	return func(c *config) {
+		c.key = key
+	}
+}
+
+// WithKeyID sets the kid header an issuer stamps on its tokens, so that
+// verifiers can pick the matching key out of a key set.
+func WithKeyID(kid string) Option {
+	return func(c *config) {
+		c.keyID = kid
+	}
+}
+
+// WithKeyResolver makes a verifier look up the verification key of every
+// token with the given resolver, in place of a single key.
+func WithKeyResolver(resolver KeyResolver) Option {
+	return func(c *config) {
+		c.resolver = resolver
+	}
+}
+
+// WithKeyring makes an issuer sign with the current key of the keyring and a
+// verifier accept the current and retired keys of it, in place of a single key.
+func WithKeyring(keyring *Keyring) Option {
+	return func(c *config) {
+		c.keyring = keyring
+	}
+}
+
+// WithAlgorithm sets the signing method, by default the one in the os env or HS512.
+// Unless WithAllowedAlgorithms says otherwise verifiers accept only this method,
+// or the methods of its keys for a keyring.
+func WithAlgorithm(signingMethod string) Option {
+	return func(c *config) {
+		c.algorithm = signingMethod
+	}
+}
+
+// WithAllowedAlgorithms sets the signing methods a verifier accepts tokens for,
+// a token naming any other method in its alg header is rejected.
+func WithAllowedAlgorithms(signingMethods ...string) Option {
+	return func(c *config) {
+		c.allowed = signingMethods
+	}
+}
+
+// WithHeader sets the request header the token is read from, Authorization by default.
+func WithHeader(header string) Option {
+	return func(c *config) {
+		c.header = header
+	}
+}
+
+// WithExtractor sets how filters read the token from the request, by default
+// BearerExtractor for the header given with WithHeader.
+func WithExtractor(extractor Extractor) Option {
+	return func(c *config) {
+		c.extractor = extractor
+	}
+}
+
+// WithRealm sets the realm filters name in the WWW-Authenticate header.
+func WithRealm(realm string) Option {
+	return func(c *config) {
+		c.realm = realm
+	}
+}
+
+// WithErrorHandler sets how filters answer requests they refuse, BearerErrorHandler by default.
+func WithErrorHandler(errorHandler ErrorHandler) Option {
+	return func(c *config) {
+		c.errorHandler = errorHandler
+	}
+}
+
+// WithExpiry sets how long a token stays valid after it is issued or refreshed,
+// ExpirationTime seconds by default.
+func WithExpiry(expiry time.Duration) Option {
+	return func(c *config) {
+		c.expiry = expiry
+	}
+}
+
+// WithRefreshWindow sets how close to its expiration a token has to be before Refresh
+// renews it, one hour by default. With a window of zero tokens are always renewed.
+func WithRefreshWindow(window time.Duration) Option {
+	return func(c *config) {
+		c.refreshWindow = window
+	}
+}
+
+// WithMaxSessionLifetime sets how long after the user authenticated Refresh keeps renewing
+// tokens, counted from the auth_time claim or else the iat claim. Unlimited by default.
+func WithMaxSessionLifetime(lifetime time.Duration) Option {
+	return func(c *config) {
+		c.maxSession = lifetime
+	}
+}
+
+// WithLeeway sets the clock skew tolerated when checking exp, nbf, iat and the max age.
+func WithLeeway(leeway time.Duration) Option {
+	return func(c *config) {
+		c.leeway = leeway
+	}
+}
+
+// WithIssuers sets the iss claims a verifier accepts, by default the ones in the os env.
+// Issuers stamp the first one on tokens that carry no iss.
+func WithIssuers(issuers ...string) Option {
+	return func(c *config) {
+		c.issuers = issuers
+	}
+}
+
+// WithAudiences sets the aud claims a verifier accepts, a token is accepted when it is meant for
+// any of them. By default the ones in the os env. Issuers stamp them on tokens that carry no aud.
+func WithAudiences(audiences ...string) Option {
+	return func(c *config) {
+		c.audiences = audiences
+	}
+}
+
+// WithRequiredClaims sets claims a verifier rejects tokens without.
+func WithRequiredClaims(claims ...string) Option {
+	return func(c *config) {
+		c.required = claims
+	}
+}
+
+// WithMaxAge makes a verifier reject tokens issued longer ago than the given age,
+// whatever their exp says. Tokens without iat are rejected then.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(c *config) {
+		c.maxAge = maxAge
+	}
+}
+
+// Values of the token_use claim, which tells tokens meant for different uses apart.
+const (
+	TokenUseAccess = "access"
+	TokenUseID     = "id"
+)
+
+// WithTokenUse sets the token_use claim a verifier accepts, TokenUseAccess by default. Tokens
+// without token_use are accepted, tokens marked for another use, such as ID tokens, are not.
+func WithTokenUse(use string) Option {
+	return func(c *config) {
+		c.tokenUse = use
+	}
+}
+
+// WithLogger sets where failures are reported as lines of text, nowhere by default.
+func WithLogger(logger Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithEventLogger sets where failures are reported as structured events, with the reason,
+// alg, kid, sub and request id (from the X-Request-Id header) of the rejected token. An
+// *slog.Logger can be passed as is. Nowhere by default.
+func WithEventLogger(events EventLogger) Option {
+	return func(c *config) {
+		c.events = events
+	}
+}
+
+// WithLogRateLimit sets how many failures are reported per interval, ten per second by default.
+// The number of failures left out is reported with the next one, a rate of zero lifts the limit.
+func WithLogRateLimit(rate int, interval time.Duration) Option {
+	return func(c *config) {
+		c.logRate, c.logInterval = rate, interval
+	}
+}
+
+// WithRevocationStore sets the store a verifier consults for revoked tokens,
+// by default the one of DefaultRevocationStore.
+func WithRevocationStore(store RevocationStore) Option {
+	return func(c *config) {
+		c.revocation = store
+	}
+}
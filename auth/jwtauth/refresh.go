@@ -0,0 +1,246 @@
+package jwtauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// refreshTokenPrefix marks opaque refresh tokens, so they are not mistaken for access tokens.
+const refreshTokenPrefix = "rt_"
+
+// TokenPair is a short lived access token along with the refresh token that renews it.
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	ExpiresAt        time.Time
+	RefreshExpiresAt time.Time
+	// Claims the access token was issued for.
+	Claims jwt.MapClaims
+}
+
+// RefreshRecord is what a RefreshStore keeps of a refresh token, the token itself
+// is never stored, only its hash.
+type RefreshRecord struct {
+	// Hash of the refresh token.
+	Hash string
+	// Family groups the refresh tokens rotated out of the same login.
+	Family string
+	// Claims the access tokens renewed with the refresh token are issued for.
+	Claims jwt.MapClaims
+	// ExpiresAt is when the refresh token stops being accepted.
+	ExpiresAt time.Time
+	// Used is set once the refresh token was rotated.
+	Used bool
+	// Revoked is set once the family of the refresh token was revoked.
+	Revoked bool
+}
+
+// RefreshStore keeps the refresh token records, implementations must be safe for concurrent use.
+type RefreshStore interface {
+	// Save stores a new record.
+	Save(record RefreshRecord) error
+	// Lookup returns the record with the given hash, ErrRefreshTokenInvalid when there is none.
+	Lookup(hash string) (RefreshRecord, error)
+	// Use marks the record with the given hash as used and returns it as it was before,
+	// ErrRefreshTokenInvalid when there is none.
+	Use(hash string) (RefreshRecord, error)
+	// RevokeFamily revokes every record of the given family.
+	RevokeFamily(family string) error
+}
+
+// RefreshManager issues access tokens along with opaque refresh tokens. Every refresh token
+// can be used once, using it rotates it for a new one of the same family, and using a rotated
+// out refresh token again revokes the whole family, so a leaked refresh token is only good
+// until either the thief or the owner uses it after the other did.
+type RefreshManager struct {
+	issuer         *Issuer
+	store          RefreshStore
+	ttl            time.Duration
+	accessLifetime time.Duration
+}
+
+// RefreshOption configures a RefreshManager.
+type RefreshOption func(*RefreshManager)
+
+// WithAccessLifetime sets how long the access tokens of a refresh manager live,
+// by default the expiry of its issuer.
+func WithAccessLifetime(lifetime time.Duration) RefreshOption {
+	return func(m *RefreshManager) {
+		m.accessLifetime = lifetime
+	}
+}
+
+// NewRefreshManager creates a refresh manager issuing access tokens with the given issuer
+// and refresh tokens valid for the given time to live.
+func NewRefreshManager(issuer *Issuer, store RefreshStore, ttl time.Duration, opts ...RefreshOption) *RefreshManager {
+	m := &RefreshManager{issuer: issuer, store: store, ttl: ttl, accessLifetime: issuer.expiry}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Issue starts a new token family for the given claims, for example after a login.
+func (m *RefreshManager) Issue(claims jwt.MapClaims) (*TokenPair, error) {
+	family, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return m.issue(family, claims)
+}
+
+// Refresh rotates the given refresh token for a new token pair.
+func (m *RefreshManager) Refresh(refreshToken string) (*TokenPair, error) {
+	record, err := m.store.Use(hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if record.Revoked || time.Now().After(record.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if record.Used {
+		if err := m.store.RevokeFamily(record.Family); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	return m.issue(record.Family, record.Claims)
+}
+
+// Lookup returns the record of the given refresh token as long as it can still be used,
+// ErrRefreshTokenInvalid otherwise. Unlike Refresh it leaves the refresh token as it is.
+func (m *RefreshManager) Lookup(refreshToken string) (RefreshRecord, error) {
+	record, err := m.store.Lookup(hashRefreshToken(refreshToken))
+	if err != nil {
+		return RefreshRecord{}, err
+	}
+	if record.Used || record.Revoked || time.Now().After(record.ExpiresAt) {
+		return RefreshRecord{}, ErrRefreshTokenInvalid
+	}
+	return record, nil
+}
+
+// Revoke revokes the family of the given refresh token, for example on logout.
+func (m *RefreshManager) Revoke(refreshToken string) error {
+	record, err := m.store.Use(hashRefreshToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	return m.store.RevokeFamily(record.Family)
+}
+
+func (m *RefreshManager) issue(family string, claims jwt.MapClaims) (*TokenPair, error) {
+	now := time.Now()
+	accessClaims := jwt.MapClaims{}
+	for key, value := range claims {
+		accessClaims[key] = value
+	}
+	expiresAt := now.Add(m.accessLifetime)
+	accessClaims["iat"] = now.Unix()
+	accessClaims["exp"] = expiresAt.Unix()
+	accessToken, err := m.issuer.Generate(accessClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken := refreshTokenPrefix + secret
+	record := RefreshRecord{
+		Hash:      hashRefreshToken(refreshToken),
+		Family:    family,
+		Claims:    claims,
+		ExpiresAt: now.Add(m.ttl),
+	}
+	if err := m.store.Save(record); err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresAt:        expiresAt,
+		RefreshExpiresAt: record.ExpiresAt,
+		Claims:           accessClaims,
+	}, nil
+}
+
+// MemoryRefreshStore keeps refresh token records in memory, they are lost on restart.
+type MemoryRefreshStore struct {
+	mu      sync.Mutex
+	records map[string]*RefreshRecord
+}
+
+// NewMemoryRefreshStore creates an empty in memory refresh store.
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{records: map[string]*RefreshRecord{}}
+}
+
+// Save stores a new record and drops the expired ones.
+func (s *MemoryRefreshStore) Save(record RefreshRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for hash, stored := range s.records {
+		if now.After(stored.ExpiresAt) {
+			delete(s.records, hash)
+		}
+	}
+	s.records[record.Hash] = &record
+	return nil
+}
+
+// Lookup returns the record with the given hash.
+func (s *MemoryRefreshStore) Lookup(hash string) (RefreshRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, found := s.records[hash]
+	if !found {
+		return RefreshRecord{}, ErrRefreshTokenInvalid
+	}
+	return *record, nil
+}
+
+// Use marks the record with the given hash as used and returns it as it was before.
+func (s *MemoryRefreshStore) Use(hash string) (RefreshRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, found := s.records[hash]
+	if !found {
+		return RefreshRecord{}, ErrRefreshTokenInvalid
+	}
+	previous := *record
+	record.Used = true
+	return previous, nil
+}
+
+// RevokeFamily revokes every record of the given family.
+func (s *MemoryRefreshStore) RevokeFamily(family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.records {
+		if record.Family == family {
+			record.Revoked = true
+		}
+	}
+	return nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return encodeBase64(token), nil
+}
@@ -0,0 +1,82 @@
+package jwtauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func newTestRefreshManager(t *testing.T) *RefreshManager {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	return NewRefreshManager(issuer, NewMemoryRefreshStore(), time.Hour)
+}
+
+func TestRefreshManagerRotation(t *testing.T) {
+	manager := newTestRefreshManager(t)
+	pair, err := manager.Issue(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error issuing tokens ->> %s", err)
+	}
+	claims, err := manager.issuer.Verifier().Parse(pair.AccessToken)
+	if err != nil || claims["sub"] != "someone" {
+		t.Fatalf("expected a valid access token for someone ->> %v", err)
+	}
+
+	rotated, err := manager.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("error refreshing tokens ->> %s", err)
+	}
+	if rotated.RefreshToken == pair.RefreshToken {
+		t.Fatal("expected the refresh token to be rotated")
+	}
+	if _, err := manager.issuer.Verifier().Parse(rotated.AccessToken); err != nil {
+		t.Fatalf("error parsing refreshed access token ->> %s", err)
+	}
+	if record, err := manager.Lookup(rotated.RefreshToken); err != nil || record.Claims["sub"] != "someone" {
+		t.Fatalf("expected the rotated refresh token to be found ->> %v", err)
+	}
+	if _, err := manager.Lookup(pair.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected a used refresh token not to be found, found %v", err)
+	}
+}
+
+func TestRefreshManagerReuseRevokesFamily(t *testing.T) {
+	manager := newTestRefreshManager(t)
+	pair, err := manager.Issue(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error issuing tokens ->> %s", err)
+	}
+	rotated, err := manager.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("error refreshing tokens ->> %s", err)
+	}
+
+	if _, err := manager.Refresh(pair.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused found %v", err)
+	}
+	if _, err := manager.Refresh(rotated.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected the family to be revoked found %v", err)
+	}
+}
+
+func TestRefreshManagerRevoke(t *testing.T) {
+	manager := newTestRefreshManager(t)
+	pair, err := manager.Issue(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error issuing tokens ->> %s", err)
+	}
+	if err := manager.Revoke(pair.RefreshToken); err != nil {
+		t.Fatalf("error revoking tokens ->> %s", err)
+	}
+	if _, err := manager.Refresh(pair.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected ErrRefreshTokenInvalid found %v", err)
+	}
+	if _, err := manager.Refresh("rt_unknown"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected ErrRefreshTokenInvalid found %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+package jwtauth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// RevocationStore denies tokens before their expiration, implementations must be safe
+// for concurrent use.
+type RevocationStore interface {
+	// Revoke denies the token the issuer gave the given jti until the given time, usually its
+	// expiration. The issuer is empty for tokens without iss.
+	Revoke(issuer string, jti string, until time.Time) error
+	// RevokeSubject denies every token the issuer gave the subject before the given time,
+	// the issuer is empty for tokens without iss.
+	RevokeSubject(issuer string, subject string, issuedBefore time.Time) error
+	// IsRevoked reports whether a token with the given issuer, jti, subject and issue time is denied,
+	// issuer, jti and subject are empty and issuedAt is zero when the token does not carry them.
+	IsRevoked(issuer string, jti string, subject string, issuedAt time.Time) (bool, error)
+}
+
+// revocationList is what revocation stores keep, and how the file store writes it.
+type revocationList struct {
+	// Tokens maps an issuer and the jti of a revoked token of it to when it can be forgotten.
+	Tokens map[string]map[string]time.Time `json:"tokens"`
+	// Subjects maps an issuer and a subject of it to the tokens of the subject that were revoked.
+	Subjects map[string]map[string]revokedSubject `json:"subjects"`
+}
+
+type revokedSubject struct {
+	IssuedBefore time.Time `json:"issued_before"`
+	Until        time.Time `json:"until"`
+}
+
+// MemoryRevocationStore keeps revoked tokens in memory until they expire, the revocations
+// are lost on restart.
+type MemoryRevocationStore struct {
+	ttl time.Duration
+
+	mu   sync.RWMutex
+	list revocationList
+}
+
+// NewMemoryRevocationStore creates an empty in memory revocation store. Revoked subjects, and
+// tokens revoked without expiration, are remembered for the time to live, which should be at
+// least as long as the lifetime of the tokens.
+func NewMemoryRevocationStore(ttl time.Duration) *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		ttl:  ttl,
+		list: revocationList{Tokens: map[string]map[string]time.Time{}, Subjects: map[string]map[string]revokedSubject{}},
+	}
+}
+
+// Revoke denies the token the issuer gave the given jti until the given time.
+func (s *MemoryRevocationStore) Revoke(issuer string, jti string, until time.Time) error {
+	if jti == "" {
+		return errors.New("invalid jti")
+	}
+	if until.IsZero() {
+		until = time.Now().Add(s.ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if s.list.Tokens[issuer] == nil {
+		s.list.Tokens[issuer] = map[string]time.Time{}
+	}
+	s.list.Tokens[issuer][jti] = until
+	return nil
+}
+
+// RevokeSubject denies every token the issuer gave the subject before the given time. The
+// iat claim only has second precision, so tokens issued within the same second are kept.
+func (s *MemoryRevocationStore) RevokeSubject(issuer string, subject string, issuedBefore time.Time) error {
+	if subject == "" {
+		return errors.New("invalid subject")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	if s.list.Subjects[issuer] == nil {
+		s.list.Subjects[issuer] = map[string]revokedSubject{}
+	}
+	s.list.Subjects[issuer][subject] = revokedSubject{
+		IssuedBefore: issuedBefore.Truncate(time.Second),
+		Until:        time.Now().Add(s.ttl),
+	}
+	return nil
+}
+
+// IsRevoked reports whether a token with the given issuer, jti, subject and issue time is denied.
+// Tokens of a revoked subject without issue time are denied as well.
+func (s *MemoryRevocationStore) IsRevoked(issuer string, jti string, subject string, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	if until, found := s.list.Tokens[issuer][jti]; found && jti != "" && now.Before(until) {
+		return true, nil
+	}
+	if revoked, found := s.list.Subjects[issuer][subject]; found && subject != "" && now.Before(revoked.Until) {
+		return issuedAt.IsZero() || issuedAt.Before(revoked.IssuedBefore), nil
+	}
+	return false, nil
+}
+
+// snapshot returns a copy of the revocations.
+func (s *MemoryRevocationStore) snapshot() revocationList {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := revocationList{Tokens: map[string]map[string]time.Time{}, Subjects: map[string]map[string]revokedSubject{}}
+	for issuer, tokens := range s.list.Tokens {
+		list.Tokens[issuer] = map[string]time.Time{}
+		for jti, until := range tokens {
+			list.Tokens[issuer][jti] = until
+		}
+	}
+	for issuer, subjects := range s.list.Subjects {
+		list.Subjects[issuer] = map[string]revokedSubject{}
+		for subject, revoked := range subjects {
+			list.Subjects[issuer][subject] = revoked
+		}
+	}
+	return list
+}
+
+// prune drops the revocations that are no longer needed, the lock must be held.
+func (s *MemoryRevocationStore) prune() {
+	now := time.Now()
+	for issuer, tokens := range s.list.Tokens {
+		for jti, until := range tokens {
+			if now.After(until) {
+				delete(tokens, jti)
+			}
+		}
+		if len(tokens) == 0 {
+			delete(s.list.Tokens, issuer)
+		}
+	}
+	for issuer, subjects := range s.list.Subjects {
+		for subject, revoked := range subjects {
+			if now.After(revoked.Until) {
+				delete(subjects, subject)
+			}
+		}
+		if len(subjects) == 0 {
+			delete(s.list.Subjects, issuer)
+		}
+	}
+}
+
+// FileRevocationStore keeps revoked tokens in memory like MemoryRevocationStore and writes
+// them to a JSON file on every change, so they survive a restart. The file is meant for a
+// single process, processes sharing revocations need a shared store.
+type FileRevocationStore struct {
+	*MemoryRevocationStore
+	path string
+
+	mu sync.Mutex
+}
+
+// NewFileRevocationStore creates a revocation store backed by the file at the given path,
+// loading the revocations it already holds.
+func NewFileRevocationStore(path string, ttl time.Duration) (*FileRevocationStore, error) {
+	store := &FileRevocationStore{MemoryRevocationStore: NewMemoryRevocationStore(ttl), path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read revocations from %s", path)
+	}
+	if err := json.Unmarshal(data, &store.list); err != nil {
+		return nil, errors.Wrapf(err, "unable to read revocations from %s", path)
+	}
+	if store.list.Tokens == nil {
+		store.list.Tokens = map[string]map[string]time.Time{}
+	}
+	if store.list.Subjects == nil {
+		store.list.Subjects = map[string]map[string]revokedSubject{}
+	}
+	store.prune()
+	return store, nil
+}
+
+// Revoke denies the token the issuer gave the given jti until the given time.
+func (s *FileRevocationStore) Revoke(issuer string, jti string, until time.Time) error {
+	if err := s.MemoryRevocationStore.Revoke(issuer, jti, until); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// RevokeSubject denies every token the issuer gave the subject before the given time.
+func (s *FileRevocationStore) RevokeSubject(issuer string, subject string, issuedBefore time.Time) error {
+	if err := s.MemoryRevocationStore.RevokeSubject(issuer, subject, issuedBefore); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// save writes the revocations to a temporary file first, so a crash cannot leave half a file behind.
+func (s *FileRevocationStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "unable to write revocations to %s", s.path)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return errors.Wrapf(err, "unable to write revocations to %s", s.path)
+	}
+	if err := temp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to write revocations to %s", s.path)
+	}
+	return errors.Wrapf(os.Rename(temp.Name(), s.path), "unable to write revocations to %s", s.path)
+}
+
+// revocation holds the store verifiers consult unless they were given their own.
+var revocation = struct {
+	sync.RWMutex
+	store RevocationStore
+}{store: NewMemoryRevocationStore(authenv.ExpirationTime * time.Second)}
+
+// DefaultRevocationStore returns the store verifiers consult unless they were created with
+// WithRevocationStore, an in memory store until SetRevocationStore replaces it.
+func DefaultRevocationStore() RevocationStore {
+	revocation.RLock()
+	defer revocation.RUnlock()
+	return revocation.store
+}
+
+// SetRevocationStore replaces the default revocation store, for example with a
+// FileRevocationStore or a store shared between processes.
+func SetRevocationStore(store RevocationStore) {
+	revocation.Lock()
+	defer revocation.Unlock()
+	revocation.store = store
+}
+
+// RevokeToken revokes the token in the given header value, as verified by the default verifier.
+func RevokeToken(headerValue string) error {
+	token, err := headerToken(headerValue)
+	if err != nil {
+		return err
+	}
+	verifier, err := DefaultVerifier()
+	if err != nil {
+		return err
+	}
+	return verifier.Revoke(token)
+}
+
+// RevokeSubject revokes every token the issuer gave the subject before the given time in the
+// default revocation store, for example to log a user out everywhere. The issuer is the iss
+// claim of the tokens, empty for tokens without it.
+func RevokeSubject(issuer string, subject string, issuedBefore time.Time) error {
+	return DefaultRevocationStore().RevokeSubject(issuer, subject, issuedBefore)
+}
+
+// Revoke verifies the given token and revokes it by its jti until it expires.
+func (v *Verifier) Revoke(token string) error {
+	if v.tenants != nil {
+		_, tenant, err := v.route(nil, token)
+		if err != nil {
+			return err
+		}
+		return tenant.Revoke(token)
+	}
+	parseToken, err := v.parse(nil, token, jwt.MapClaims{})
+	if err != nil {
+		return err
+	}
+	payload := parseToken.Claims.(jwt.MapClaims)
+	jti, _ := payload["jti"].(string)
+	if jti == "" {
+		return errors.New("token has no jti")
+	}
+	issuer, _ := payload["iss"].(string)
+	var until time.Time
+	if exp, ok := numericClaim(payload, "exp"); ok {
+		until = time.Unix(exp, 0).Add(v.leeway)
+	}
+	return v.revocationStore().Revoke(issuer, jti, until)
+}
+
+// revocationStore returns the store of the verifier, or else the default one.
+func (v *Verifier) revocationStore() RevocationStore {
+	if v.revocation != nil {
+		return v.revocation
+	}
+	return DefaultRevocationStore()
+}
+
+// checkRevoked returns ErrRevoked for tokens denied by the revocation store.
+func (v *Verifier) checkRevoked(payload map[string]interface{}) error {
+	jti, _ := payload["jti"].(string)
+	issuer, _ := payload["iss"].(string)
+	subject, _ := payload["sub"].(string)
+	var issuedAt time.Time
+	if iat, ok := numericClaim(payload, "iat"); ok {
+		issuedAt = time.Unix(iat, 0)
+	}
+	revoked, err := v.revocationStore().IsRevoked(issuer, jti, subject, issuedAt)
+	if err != nil {
+		return errors.Wrap(err, "unable to check revocation")
+	}
+	if revoked {
+		return ErrRevoked
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package jwtauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestGenerateStampsTokenID(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	token, err := issuer.Generate(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	claims, err := issuer.Verifier().Parse(token)
+	if err != nil {
+		t.Fatalf("error parsing token ->> %s", err)
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		t.Fatal("expected the token to carry a jti")
+	}
+}
+
+func TestVerifierRevoke(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour)
+	issuer, err := NewIssuer(WithKey(tokenKey), WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	token, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	other, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	if err := issuer.Verifier().Revoke(token); err != nil {
+		t.Fatalf("error revoking token ->> %s", err)
+	}
+	if _, err := issuer.Verifier().Parse(token); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("expected ErrRevoked found %v", err)
+	}
+	if !issuer.Verifier().IsValid(other) {
+		t.Fatal("expected the other token to stay valid")
+	}
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	issuer.Verifier().Filter(okHandler()).ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestRevokeSubject(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour)
+	issuer, err := NewIssuer(WithKey(tokenKey), WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	now := time.Now()
+	before, err := issuer.Generate(jwt.MapClaims{"sub": "someone", "iat": now.Add(-2 * time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if err := store.RevokeSubject("", "someone", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("error revoking subject ->> %s", err)
+	}
+	after, err := issuer.Generate(jwt.MapClaims{"sub": "someone", "iat": now.Unix()})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	if _, err := issuer.Verifier().Parse(before); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("expected ErrRevoked found %v", err)
+	}
+	if _, err := issuer.Verifier().Parse(after); err != nil {
+		t.Fatalf("expected the token issued later to be valid ->> %s", err)
+	}
+}
+
+func TestRevokeSubjectKeepsLaterTokens(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour)
+	issuer, err := NewIssuer(WithKey(tokenKey), WithIssuers("https://auth.example.com"), WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	before, err := issuer.Generate(jwt.MapClaims{"sub": "someone", "iat": time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if err := store.RevokeSubject("https://auth.example.com", "someone", time.Now()); err != nil {
+		t.Fatalf("error revoking subject ->> %s", err)
+	}
+	after, err := issuer.Generate(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	if _, err := issuer.Verifier().Parse(before); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("expected ErrRevoked found %v", err)
+	}
+	if _, err := issuer.Verifier().Parse(after); err != nil {
+		t.Fatalf("expected the token issued right after revoking to be valid ->> %s", err)
+	}
+	if err := store.RevokeSubject("https://other.example.com", "someone", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("error revoking subject ->> %s", err)
+	}
+	if _, err := issuer.Verifier().Parse(after); err != nil {
+		t.Fatalf("expected the subject of another issuer to be left alone ->> %s", err)
+	}
+}
+
+func TestDefaultRevocationStore(t *testing.T) {
+	previous := DefaultRevocationStore()
+	SetRevocationStore(NewMemoryRevocationStore(time.Hour))
+	defer SetRevocationStore(previous)
+
+	token, err := Generate("HS512", randomMiniClaims(), tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	verifier, _ := NewVerifier(WithKey(tokenKey), WithAlgorithm("HS512"))
+	if err := verifier.Revoke(token); err != nil {
+		t.Fatalf("error revoking token ->> %s", err)
+	}
+	if IsValid("Bearer "+token, tokenKey) {
+		t.Fatal("expected the revoked token to be invalid")
+	}
+}
+
+func TestFileRevocationStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	store, err := NewFileRevocationStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("error creating store ->> %s", err)
+	}
+	if err := store.Revoke("", "some-jti", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("error revoking token ->> %s", err)
+	}
+	if err := store.RevokeSubject("https://auth.example.com", "someone", time.Now()); err != nil {
+		t.Fatalf("error revoking subject ->> %s", err)
+	}
+
+	reopened, err := NewFileRevocationStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("error reopening store ->> %s", err)
+	}
+	if revoked, _ := reopened.IsRevoked("", "some-jti", "", time.Time{}); !revoked {
+		t.Fatal("expected the jti to stay revoked")
+	}
+	if revoked, _ := reopened.IsRevoked("https://auth.example.com", "", "someone", time.Now().Add(-time.Minute)); !revoked {
+		t.Fatal("expected the subject to stay revoked")
+	}
+	if revoked, _ := reopened.IsRevoked("https://auth.example.com", "other-jti", "someone else", time.Now()); revoked {
+		t.Fatal("expected other tokens not to be revoked")
+	}
+	if revoked, _ := reopened.IsRevoked("https://other.example.com", "", "someone", time.Now().Add(-time.Minute)); revoked {
+		t.Fatal("expected the subject of another issuer not to be revoked")
+	}
+}
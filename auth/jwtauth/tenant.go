@@ -0,0 +1,129 @@
+package jwtauth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// TenantSelector names the tenant a token belongs to, from the request it came with, which
+// is nil outside of filters, or from its claims, which are not verified yet at that point.
+type TenantSelector func(r *http.Request, payload map[string]interface{}) (tenant string, err error)
+
+// TenantByIssuer selects the tenant named by the iss claim, tenants are then keyed by issuer.
+func TenantByIssuer() TenantSelector {
+	return TenantByClaim("iss")
+}
+
+// TenantByClaim selects the tenant named by the given string claim, such as "tenant_id".
+func TenantByClaim(name string) TenantSelector {
+	return func(r *http.Request, payload map[string]interface{}) (string, error) {
+		tenant, _ := payload[name].(string)
+		if tenant == "" {
+			return "", &ClaimError{Claim: name, Err: ErrMissingClaim}
+		}
+		return tenant, nil
+	}
+}
+
+// TenantByHost selects the tenant named by the host of the request, without its port,
+// such as "acme.example.com". It only works in filters.
+func TenantByHost() TenantSelector {
+	return func(r *http.Request, payload map[string]interface{}) (string, error) {
+		if r == nil {
+			return "", errors.New("no request to take the host from")
+		}
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		return strings.ToLower(host), nil
+	}
+}
+
+// TenantByPath selects the tenant named by the path segment following the given prefix,
+// "acme" for /tenants/acme/orders with the prefix "/tenants/". It only works in filters.
+func TenantByPath(prefix string) TenantSelector {
+	return func(r *http.Request, payload map[string]interface{}) (string, error) {
+		if r == nil {
+			return "", errors.New("no request to take the path from")
+		}
+		rest := strings.TrimPrefix(r.URL.Path, prefix)
+		if rest == r.URL.Path && prefix != "" {
+			return "", errors.Errorf("path does not start with %s", prefix)
+		}
+		tenant := strings.SplitN(rest, "/", 2)[0]
+		if tenant == "" {
+			return "", errors.New("path names no tenant")
+		}
+		return tenant, nil
+	}
+}
+
+// WithTenants makes a verifier route every token to the verifier of its tenant, which checks it
+// with its own keys, issuers, audiences and other rules. To keep tenants strictly apart, every
+// tenant verifier needs issuers of its own given with WithIssuers, so that a token of one tenant
+// can never pass for a token of another, even when the selector is fooled or keys are shared.
+// The extractor and error handler of the routing verifier are used, the ones of tenants are not.
+func WithTenants(selector TenantSelector, tenants map[string]*Verifier) Option {
+	return func(c *config) {
+		c.selector = selector
+		c.tenants = tenants
+	}
+}
+
+// checkTenants makes sure the tenants of a routing verifier cannot accept each other's tokens.
+func checkTenants(selector TenantSelector, tenants map[string]*Verifier) error {
+	if selector == nil || len(tenants) == 0 {
+		return errors.New("invalid tenants")
+	}
+	owners := map[string]string{}
+	for tenant, verifier := range tenants {
+		if verifier == nil || verifier.tenants != nil {
+			return errors.Errorf("invalid verifier for tenant %q", tenant)
+		}
+		if len(verifier.issuers) == 0 {
+			return errors.Errorf("verifier for tenant %q accepts any issuer", tenant)
+		}
+		for _, issuer := range verifier.issuers {
+			if owner, found := owners[issuer]; found {
+				return errors.Errorf("tenants %q and %q share issuer %s", owner, tenant, issuer)
+			}
+			owners[issuer] = tenant
+		}
+	}
+	return nil
+}
+
+// route returns the tenant of the token and its verifier, ErrUnknownTenant when there is none.
+func (v *Verifier) route(r *http.Request, token string) (string, *Verifier, error) {
+	payload, err := decodePayload(token)
+	if err != nil {
+		return "", nil, tokenError(err)
+	}
+	tenant, err := v.selector(r, payload)
+	if err != nil {
+		return "", nil, &TokenError{Reason: ErrUnknownTenant, Err: err}
+	}
+	verifier, found := v.tenants[tenant]
+	if !found {
+		return "", nil, &TokenError{Reason: ErrUnknownTenant, Err: errors.Errorf("unknown tenant %q", tenant)}
+	}
+	return tenant, verifier, nil
+}
+
+// claimsContext returns the context of the request carrying the verified claims, and the
+// tenant they belong to for a routing verifier.
+func (v *Verifier) claimsContext(r *http.Request, claims jwt.MapClaims) context.Context {
+	ctx := ContextWithClaims(r.Context(), claims)
+	if v.tenants != nil {
+		if tenant, err := v.selector(r, claims); err == nil {
+			ctx = ContextWithTenant(ctx, tenant)
+		}
+	}
+	return ctx
+}
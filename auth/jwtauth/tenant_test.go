@@ -0,0 +1,219 @@
+package jwtauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type testTenant struct {
+	issuer   *Issuer
+	verifier *Verifier
+}
+
+func newTestTenant(t *testing.T, iss string, key string) testTenant {
+	opts := []Option{WithKey([]byte(key)), WithAlgorithm("HS256"), WithIssuers(iss), WithAudiences("orders"), WithRevocationStore(NewMemoryRevocationStore(0))}
+	issuer, err := NewIssuer(opts...)
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	return testTenant{issuer: issuer, verifier: issuer.Verifier()}
+}
+
+func (tenant testTenant) token(t *testing.T, claims jwt.MapClaims) string {
+	token, err := tenant.issuer.Generate(claims)
+	if err != nil {
+		t.Fatalf("error generating token ->> %s", err)
+	}
+	return token
+}
+
+func TestTenantsByIssuer(t *testing.T) {
+	acme := newTestTenant(t, "https://acme.example.com", "acme-key")
+	globex := newTestTenant(t, "https://globex.example.com", "globex-key")
+	router, err := NewVerifier(WithTenants(TenantByIssuer(), map[string]*Verifier{
+		"https://acme.example.com":   acme.verifier,
+		"https://globex.example.com": globex.verifier,
+	}))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	for _, tenant := range []testTenant{acme, globex} {
+		if _, err := router.Parse(tenant.token(t, jwt.MapClaims{"sub": "someone"})); err != nil {
+			t.Fatalf("error parsing token of a tenant ->> %s", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"other key", acme.token(t, jwt.MapClaims{"iss": "https://globex.example.com"}), ErrSignatureInvalid},
+		{"unknown issuer", acme.token(t, jwt.MapClaims{"iss": "https://initech.example.com"}), ErrUnknownTenant},
+		{"no issuer", acme.token(t, jwt.MapClaims{"iss": ""}), ErrUnknownTenant},
+		{"malformed", "not-a-token", ErrMalformed},
+	}
+	for _, test := range tests {
+		if _, err := router.Parse(test.token); !errors.Is(err, test.err) {
+			t.Fatalf("%s: expected %v found %v", test.name, test.err, err)
+		}
+	}
+
+	// Revocation goes to the store of the tenant.
+	token := acme.token(t, jwt.MapClaims{"sub": "someone"})
+	if err := router.Revoke(token); err != nil {
+		t.Fatalf("error revoking token ->> %s", err)
+	}
+	if err := acme.verifier.Validate(token); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("expected ErrRevoked found %v", err)
+	}
+}
+
+func TestTenantsRevocation(t *testing.T) {
+	previous := DefaultRevocationStore()
+	SetRevocationStore(NewMemoryRevocationStore(time.Hour))
+	defer SetRevocationStore(previous)
+
+	// Both tenants consult the default store, revocations of one must not reach the other.
+	tenant := func(iss string) testTenant {
+		issuer, err := NewIssuer(WithKey([]byte("shared-key")), WithAlgorithm("HS256"), WithIssuers(iss))
+		if err != nil {
+			t.Fatalf("error creating issuer ->> %s", err)
+		}
+		return testTenant{issuer: issuer, verifier: issuer.Verifier()}
+	}
+	acme, globex := tenant("https://acme.example.com"), tenant("https://globex.example.com")
+	router, err := NewVerifier(WithTenants(TenantByIssuer(), map[string]*Verifier{
+		"https://acme.example.com":   acme.verifier,
+		"https://globex.example.com": globex.verifier,
+	}))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	claims := jwt.MapClaims{"sub": "42", "jti": "same-jti", "iat": time.Now().Add(-time.Minute).Unix()}
+	acmeToken, globexToken := acme.token(t, claims), globex.token(t, claims)
+
+	if err := router.Revoke(acmeToken); err != nil {
+		t.Fatalf("error revoking token ->> %s", err)
+	}
+	if err := RevokeSubject("https://acme.example.com", "42", time.Now()); err != nil {
+		t.Fatalf("error revoking subject ->> %s", err)
+	}
+	if _, err := router.Parse(acmeToken); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("expected ErrRevoked found %v", err)
+	}
+	if _, err := router.Parse(globexToken); err != nil {
+		t.Fatalf("expected the token of the other tenant to stay valid ->> %s", err)
+	}
+}
+
+func TestTenantsSharingKey(t *testing.T) {
+	// Even with the same key, the issuers keep the tenants apart.
+	acme := newTestTenant(t, "https://acme.example.com", "shared-key")
+	globex := newTestTenant(t, "https://globex.example.com", "shared-key")
+	router, err := NewVerifier(WithTenants(TenantByClaim("tenant_id"), map[string]*Verifier{"acme": acme.verifier, "globex": globex.verifier}))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	if _, err := router.Parse(acme.token(t, jwt.MapClaims{"tenant_id": "acme"})); err != nil {
+		t.Fatalf("error parsing token of a tenant ->> %s", err)
+	}
+	if _, err := router.Parse(acme.token(t, jwt.MapClaims{"tenant_id": "globex"})); !errors.Is(err, ErrIssuerMismatch) {
+		t.Fatalf("expected ErrIssuerMismatch found %v", err)
+	}
+	if _, err := router.Parse(acme.token(t, jwt.MapClaims{})); !errors.Is(err, ErrUnknownTenant) {
+		t.Fatalf("expected ErrUnknownTenant found %v", err)
+	}
+}
+
+func TestTenantsMustBeIsolated(t *testing.T) {
+	acme := newTestTenant(t, "https://acme.example.com", "acme-key")
+	anyIssuer, err := NewVerifier(WithKey([]byte("key")), WithAlgorithm("HS256"), WithIssuers())
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	sameIssuer := newTestTenant(t, "https://acme.example.com", "other-key")
+	tests := []struct {
+		name    string
+		tenants map[string]*Verifier
+	}{
+		{"none", map[string]*Verifier{}},
+		{"any issuer", map[string]*Verifier{"acme": acme.verifier, "other": anyIssuer}},
+		{"same issuer", map[string]*Verifier{"acme": acme.verifier, "other": sameIssuer.verifier}},
+	}
+	for _, test := range tests {
+		if _, err := NewVerifier(WithTenants(TenantByClaim("tenant_id"), test.tenants)); err == nil {
+			t.Fatalf("%s: expected the tenants to be refused", test.name)
+		}
+	}
+}
+
+func TestTenantsFilter(t *testing.T) {
+	acme := newTestTenant(t, "https://acme.example.com", "acme-key")
+	globex := newTestTenant(t, "https://globex.example.com", "globex-key")
+	tenants := map[string]*Verifier{"acme": acme.verifier, "globex": globex.verifier}
+	byHost, err := NewVerifier(WithTenants(func(r *http.Request, payload map[string]interface{}) (string, error) {
+		host, err := TenantByHost()(r, payload)
+		return strings.TrimSuffix(host, ".example.com"), err
+	}, tenants))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	byPath, err := NewVerifier(WithTenants(TenantByPath("/tenants/"), tenants))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+
+	serve := func(verifier *Verifier, target string, token string) (int, string) {
+		var tenant string
+		handler := verifier.Filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, _ = TenantFromContext(r.Context())
+		}))
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code, tenant
+	}
+	acmeToken, globexToken := acme.token(t, jwt.MapClaims{"sub": "someone"}), globex.token(t, jwt.MapClaims{"sub": "someone"})
+	if code, tenant := serve(byHost, "https://acme.example.com:8443/orders", acmeToken); code != http.StatusOK || tenant != "acme" {
+		t.Fatalf("expected the acme token to be accepted for acme, found %d %q", code, tenant)
+	}
+	if code, _ := serve(byHost, "https://acme.example.com/orders", globexToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, code)
+	}
+	if code, tenant := serve(byPath, "/tenants/globex/orders", globexToken); code != http.StatusOK || tenant != "globex" {
+		t.Fatalf("expected the globex token to be accepted for globex, found %d %q", code, tenant)
+	}
+	if code, _ := serve(byPath, "/tenants/acme/orders", globexToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, code)
+	}
+	if code, _ := serve(byPath, "/orders", globexToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestTenantsAsDefault(t *testing.T) {
+	acme := newTestTenant(t, "https://acme.example.com", "acme-key")
+	router, err := NewVerifier(WithTenants(TenantByIssuer(), map[string]*Verifier{"https://acme.example.com": acme.verifier}))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	SetDefault(nil, router)
+	t.Cleanup(func() { SetDefault(nil, nil) })
+
+	token := acme.token(t, jwt.MapClaims{"sub": "someone"})
+	if _, err := ParseTokenDefault("Bearer " + token); err != nil {
+		t.Fatalf("error parsing token with the default verifier ->> %s", err)
+	}
+	other := newTestTenant(t, "https://globex.example.com", "acme-key")
+	if IsValidDefault(other.token(t, jwt.MapClaims{"sub": "someone"})) {
+		t.Fatal("expected the token of an unknown tenant to be invalid")
+	}
+}
@@ -0,0 +1,367 @@
+package jwtauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// Verifier parses and validates tokens with its own key and settings, so
+// differently configured verifiers can live side by side in one process.
+type Verifier struct {
+	keys         *keyCache
+	resolver     KeyResolver
+	keyring      *Keyring
+	allowed      []string
+	header       string
+	extractor    Extractor
+	realm        string
+	errorHandler ErrorHandler
+	leeway       time.Duration
+	logger       Logger
+	events       EventLogger
+	limiter      *logLimiter
+	revocation   RevocationStore
+	issuers      []string
+	audiences    []string
+	required     []string
+	maxAge       time.Duration
+	tokenUse     string
+	tenants      map[string]*Verifier
+	selector     TenantSelector
+}
+
+// NewVerifier creates a verifier with the given options, a key, a key resolver
+// or a keyring is required. Only tokens signed with the allowed signing methods are accepted, which
+// default to the signing method in the os env. A verifier created WithTenants needs none of that,
+// it leaves the checks to the verifiers of its tenants.
+func NewVerifier(opts ...Option) (*Verifier, error) {
+	c := newConfig(opts)
+	if c.tenants != nil {
+		if err := checkTenants(c.selector, c.tenants); err != nil {
+			return nil, err
+		}
+		v := newVerifier(c, newKeyCache(nil), nil, c.allowed)
+		v.tenants, v.selector = copyTenants(c.tenants), c.selector
+		return v, nil
+	}
+	if c.resolver == nil && c.keyring != nil {
+		c.resolver = c.keyring
+	}
+	if len(c.key) == 0 && c.resolver == nil {
+		return nil, errors.New("invalid key")
+	}
+	keys := newKeyCache(c.key)
+	for _, signingMethod := range c.allowed {
+		if jwt.GetSigningMethod(signingMethod) == jwt.SigningMethodNone {
+			return nil, errors.New("unsecured tokens cannot be allowed")
+		}
+		if c.resolver != nil {
+			continue
+		}
+		if _, err := keys.verificationKey(signingMethod); err != nil {
+			return nil, err
+		}
+	}
+	return newVerifier(c, keys, c.resolver, c.allowed), nil
+}
+
+func newVerifier(c *config, keys *keyCache, resolver KeyResolver, allowed []string) *Verifier {
+	keyring, _ := resolver.(*Keyring)
+	return &Verifier{
+		keys:         keys,
+		resolver:     resolver,
+		keyring:      keyring,
+		allowed:      allowed,
+		header:       c.header,
+		extractor:    c.extractor,
+		realm:        c.realm,
+		errorHandler: c.errorHandler,
+		leeway:       c.leeway,
+		logger:       c.logger,
+		events:       c.events,
+		limiter:      newLogLimiter(c.logRate, c.logInterval),
+		revocation:   c.revocation,
+		issuers:      c.issuers,
+		audiences:    c.audiences,
+		required:     c.required,
+		maxAge:       c.maxAge,
+		tokenUse:     c.tokenUse,
+	}
+}
+
+// copyTenants copies the tenants so that the map given to WithTenants can be reused safely.
+func copyTenants(tenants map[string]*Verifier) map[string]*Verifier {
+	if tenants == nil {
+		return nil
+	}
+	copied := make(map[string]*Verifier, len(tenants))
+	for tenant, verifier := range tenants {
+		copied[tenant] = verifier
+	}
+	return copied
+}
+
+// Header returns the request header the token is read from.
+func (v *Verifier) Header() string {
+	return v.header
+}
+
+// Parse parses the given token to map claims.
+func (v *Verifier) Parse(token string) (claims jwt.MapClaims, err error) {
+	claims = jwt.MapClaims{}
+	if err := v.ParseWithClaims(token, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ParseWithClaims parses the given token to the given claims.
+func (v *Verifier) ParseWithClaims(token string, claims jwt.Claims) (err error) {
+	_, err = v.parse(nil, token, claims)
+	return err
+}
+
+// IsValid checks if the given token is a valid token.
+func (v *Verifier) IsValid(token string) bool {
+	return v.Validate(token) == nil
+}
+
+// Validate checks the given token and returns why it is not valid, an error matching
+// one of ErrTokenExpired, ErrTokenNotYetValid, ErrSignatureInvalid, ErrMalformed,
+// ErrAlgorithmNotAllowed, ErrRevoked, ErrIssuerMismatch, ErrAudienceMismatch,
+// ErrMissingKey, ErrMissingClaim, ErrInvalidClaim or ErrUnknownTenant.
+func (v *Verifier) Validate(token string) error {
+	_, err := v.parse(nil, token, jwt.MapClaims{})
+	return err
+}
+
+// parse verifies the token and reports failures to the loggers, along with the
+// request the token came with, if any.
+func (v *Verifier) parse(r *http.Request, token string, claims jwt.Claims) (*jwt.Token, error) {
+	if v.tenants != nil {
+		_, tenant, err := v.route(r, token)
+		if err != nil {
+			v.logFailure(r, token, err)
+			return nil, err
+		}
+		return tenant.parse(r, token, claims)
+	}
+	// Time based claims are checked below instead of by jwt-go, which
+	// has no notion of leeway.
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	parseToken, err := parser.ParseWithClaims(token, claims, v.keyFunc)
+	if err == nil && !parseToken.Valid {
+		err = errors.New("invalid token")
+	}
+	if err == nil {
+		err = v.validateClaims(parseToken)
+	}
+	err = tokenError(err)
+	if err != nil {
+		v.logFailure(r, token, err)
+		return nil, err
+	}
+	return parseToken, nil
+}
+
+// keyFunc returns the verification key for the signing method of the token, as long
+// as the method is allowed, so that a token cannot choose how it gets verified.
+func (v *Verifier) keyFunc(parseToken *jwt.Token) (interface{}, error) {
+	signingMethod := parseToken.Method.Alg()
+	if !v.Allows(signingMethod) {
+		return nil, &AlgorithmError{Algorithm: signingMethod}
+	}
+	var key interface{}
+	var err error
+	if v.resolver != nil {
+		key, err = v.resolver.ResolveKey(parseToken)
+	} else {
+		key, err = v.keys.verificationKey(signingMethod)
+	}
+	if _, ok := err.(*AlgorithmError); err != nil && !ok {
+		return nil, &TokenError{Reason: ErrMissingKey, Err: err}
+	}
+	return key, err
+}
+
+// Allows reports whether the verifier accepts tokens signed with the given signing method.
+// Unless WithAllowedAlgorithms was given, a keyring verifier accepts the methods of its keys.
+func (v *Verifier) Allows(signingMethod string) bool {
+	if len(v.allowed) == 0 && v.keyring != nil {
+		return v.keyring.allows(signingMethod)
+	}
+	for _, allowed := range v.allowed {
+		if allowed == signingMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// validateClaims checks the time based claims and whether the token was revoked.
+func (v *Verifier) validateClaims(parseToken *jwt.Token) error {
+	payload, err := decodePayload(parseToken.Raw)
+	if err != nil {
+		return err
+	}
+	if err := v.validateTimes(payload); err != nil {
+		return err
+	}
+	if err := v.validateAudience(payload); err != nil {
+		return err
+	}
+	for _, name := range v.required {
+		if value, found := payload[name]; !found || value == nil || value == "" {
+			return &ClaimError{Claim: name, Err: ErrMissingClaim}
+		}
+	}
+	if use, found := payload["token_use"]; found && use != v.tokenUse {
+		return &ClaimError{Claim: "token_use", Err: ErrInvalidClaim}
+	}
+	return v.checkRevoked(payload)
+}
+
+// validateAudience checks iss and aud against the accepted issuers and audiences.
+func (v *Verifier) validateAudience(payload map[string]interface{}) error {
+	if len(v.issuers) > 0 {
+		iss, _ := payload["iss"].(string)
+		if !contains(v.issuers, iss) {
+			return ErrIssuerMismatch
+		}
+	}
+	if len(v.audiences) == 0 {
+		return nil
+	}
+	// aud is either a single string or an array of them.
+	var audiences []string
+	switch aud := payload["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []interface{}:
+		for _, value := range aud {
+			if audience, ok := value.(string); ok {
+				audiences = append(audiences, audience)
+			}
+		}
+	}
+	for _, audience := range audiences {
+		if contains(v.audiences, audience) {
+			return nil
+		}
+	}
+	return ErrAudienceMismatch
+}
+
+// validateTimes checks exp, nbf and iat of the token allowing the configured leeway.
+func (v *Verifier) validateTimes(payload map[string]interface{}) error {
+	for _, name := range []string{"exp", "iat", "nbf"} {
+		if _, found := payload[name]; found {
+			if _, ok := numericClaim(payload, name); !ok {
+				return &ClaimError{Claim: name, Err: ErrInvalidClaim}
+			}
+		}
+	}
+	now := jwt.TimeFunc()
+	leeway := int64(v.leeway / time.Second)
+	if exp, ok := numericClaim(payload, "exp"); ok && now.Unix() > exp+leeway {
+		return jwt.NewValidationError("Token is expired", jwt.ValidationErrorExpired)
+	}
+	if iat, ok := numericClaim(payload, "iat"); ok && now.Unix() < iat-leeway {
+		return jwt.NewValidationError("Token used before issued", jwt.ValidationErrorIssuedAt)
+	}
+	if nbf, ok := numericClaim(payload, "nbf"); ok && now.Unix() < nbf-leeway {
+		return jwt.NewValidationError("Token is not valid yet", jwt.ValidationErrorNotValidYet)
+	}
+	if v.maxAge > 0 {
+		iat, ok := numericClaim(payload, "iat")
+		if !ok {
+			return &ClaimError{Claim: "iat", Err: ErrMissingClaim}
+		}
+		if now.Unix() > iat+int64(v.maxAge/time.Second)+leeway {
+			return jwt.NewValidationError("Token is too old", jwt.ValidationErrorExpired)
+		}
+	}
+	return nil
+}
+
+// decodePayload decodes the claims segment of the token whatever claims type it was parsed to.
+func decodePayload(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, jwt.NewValidationError("token contains an invalid number of segments", jwt.ValidationErrorMalformed)
+	}
+	segment, err := jwt.DecodeSegment(parts[1])
+	if err != nil {
+		return nil, &jwt.ValidationError{Inner: err, Errors: jwt.ValidationErrorMalformed}
+	}
+	payload := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(segment))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, &jwt.ValidationError{Inner: err, Errors: jwt.ValidationErrorMalformed}
+	}
+	return payload, nil
+}
+
+// numericClaim returns the named NumericDate claim in seconds.
+func numericClaim(payload map[string]interface{}, name string) (int64, bool) {
+	switch value := payload[name].(type) {
+	case json.Number:
+		if seconds, err := value.Int64(); err == nil {
+			return seconds, true
+		}
+		if seconds, err := value.Float64(); err == nil {
+			return int64(seconds), true
+		}
+	case float64:
+		return int64(value), true
+	case int64:
+		return value, true
+	}
+	return 0, false
+}
+
+// keyCache parses the configured key once for every signing method it is used with.
+type keyCache struct {
+	raw          []byte
+	mu           sync.Mutex
+	signing      map[string]interface{}
+	verification map[string]interface{}
+}
+
+func newKeyCache(raw []byte) *keyCache {
+	return &keyCache{
+		raw:          raw,
+		signing:      map[string]interface{}{},
+		verification: map[string]interface{}{},
+	}
+}
+
+func (k *keyCache) signingKey(signingMethod string) (interface{}, error) {
+	return k.lookup(k.signing, signingMethod, ParseSigningKey)
+}
+
+func (k *keyCache) verificationKey(signingMethod string) (interface{}, error) {
+	return k.lookup(k.verification, signingMethod, ParseVerificationKey)
+}
+
+func (k *keyCache) lookup(keys map[string]interface{}, signingMethod string, parse func(string, []byte) (interface{}, error)) (interface{}, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := keys[signingMethod]; ok {
+		return key, nil
+	}
+	key, err := parse(signingMethod, k.raw)
+	if err != nil {
+		return nil, err
+	}
+	keys[signingMethod] = key
+	return key, nil
+}
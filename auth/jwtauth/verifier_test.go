@@ -0,0 +1,454 @@
+package jwtauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestIndependentVerifiers(t *testing.T) {
+	userIssuer, err := NewIssuer(WithKey([]byte("user-api-key")), WithAlgorithm("HS256"))
+	if err != nil {
+		t.Fatalf("error creating user issuer ->> %s", err)
+	}
+	adminIssuer, err := NewIssuer(WithKey([]byte("admin-api-key")), WithAlgorithm("HS256"))
+	if err != nil {
+		t.Fatalf("error creating admin issuer ->> %s", err)
+	}
+	userToken, err := userIssuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	if !userIssuer.Verifier().IsValid(userToken) {
+		t.Fatal("expected user token to be valid for the user verifier")
+	}
+	if adminIssuer.Verifier().IsValid(userToken) {
+		t.Fatal("expected user token to be invalid for the admin verifier")
+	}
+}
+
+func TestVerifierLeeway(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	miniClaims := randomMiniClaims()
+	miniClaims.ExpiresAt = time.Now().Add(-30 * time.Second).Unix()
+	token, err := issuer.Generate(miniClaims)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	strict, _ := NewVerifier(WithKey(tokenKey))
+	if strict.IsValid(token) {
+		t.Fatal("expected expired token to be invalid without leeway")
+	}
+	lenient, _ := NewVerifier(WithKey(tokenKey), WithLeeway(time.Minute))
+	if !lenient.IsValid(token) {
+		t.Fatal("expected expired token to be valid within the leeway")
+	}
+}
+
+func TestIssuerRefreshExpiry(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey), WithExpiry(10*time.Minute))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	miniClaims := randomMiniClaims()
+	miniClaims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	token, err := issuer.Generate(miniClaims)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	refreshedToken, err := issuer.Refresh(token)
+	if err != nil {
+		t.Fatalf("error refreshing token ->> %s", err)
+	}
+	claims, err := issuer.Verifier().Parse(refreshedToken)
+	if err != nil {
+		t.Fatalf("error parsing refreshed token ->> %s", err)
+	}
+	expiresIn := time.Until(time.Unix(int64(claims["exp"].(float64)), 0))
+	if expiresIn < 9*time.Minute || expiresIn > 10*time.Minute {
+		t.Fatalf("expected the token to expire in 10 minutes, expires in %s", expiresIn)
+	}
+}
+
+func TestIssuerRefreshWindow(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey), WithRefreshWindow(0))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	token, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	refreshedToken, err := issuer.Refresh(token)
+	if err != nil {
+		t.Fatalf("error refreshing token ->> %s", err)
+	}
+	if refreshedToken == token {
+		t.Fatal("expected the token to be renewed without refresh window")
+	}
+}
+
+func TestIssuerMaxSessionLifetime(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey), WithExpiry(time.Hour), WithMaxSessionLifetime(2*time.Hour))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	now := time.Now()
+	token, err := issuer.Generate(jwt.MapClaims{"sub": "someone", "iat": now.Add(-90 * time.Minute).Unix(), "exp": now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	refreshedToken, err := issuer.Refresh(token)
+	if err != nil {
+		t.Fatalf("error refreshing token ->> %s", err)
+	}
+	claims, err := issuer.Verifier().Parse(refreshedToken)
+	if err != nil {
+		t.Fatalf("error parsing refreshed token ->> %s", err)
+	}
+	if expiresIn := time.Until(time.Unix(int64(claims["exp"].(float64)), 0)); expiresIn > 31*time.Minute {
+		t.Fatalf("expected the session to end in 30 minutes, expires in %s", expiresIn)
+	}
+	if authTime := int64(claims["auth_time"].(float64)); authTime != now.Add(-90*time.Minute).Unix() {
+		t.Fatalf("expected auth_time to keep the original iat found %d", authTime)
+	}
+
+	token, err = issuer.Generate(jwt.MapClaims{"sub": "someone", "auth_time": now.Add(-3 * time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if _, err := issuer.Refresh(token); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expected ErrSessionExpired found %v", err)
+	}
+}
+
+func TestIssuerRefreshOddClaims(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	token, err := Generate("HS512", jwt.MapClaims{"sub": "someone", "exp": "tomorrow"}, tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	var claimErr *ClaimError
+	if _, err := issuer.Refresh(token); !errors.As(err, &claimErr) || !errors.Is(err, ErrInvalidClaim) || claimErr.Claim != "exp" {
+		t.Fatalf("expected ErrInvalidClaim for exp found %v", err)
+	}
+
+	withoutExp := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{"sub": "someone"})
+	token, err = withoutExp.SignedString(tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if _, err := RefreshToken(token, tokenKey); !errors.Is(err, ErrMissingClaim) {
+		t.Fatalf("expected ErrMissingClaim found %v", err)
+	}
+}
+
+func TestGenerateWithDefaultLifetime(t *testing.T) {
+	t.Setenv(authenv.SigningMethodEnvKey, "HS512")
+	t.Setenv(authenv.TokenEnvKey, "lifetime-default-key")
+	t.Setenv(authenv.TokenExpirationKey, "600")
+
+	token, err := GenerateWithDefault(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	claims, err := ParseTokenDefault(token)
+	if err != nil {
+		t.Fatalf("error parsing token ->> %s", err)
+	}
+	mapClaims := claims.(jwt.MapClaims)
+	now := time.Now().Unix()
+	for _, name := range []string{"iat", "nbf"} {
+		if value, _ := mapClaims[name].(float64); now-int64(value) > 1 {
+			t.Fatalf("expected %s to be now found %v", name, mapClaims[name])
+		}
+	}
+	if expiresIn := int64(mapClaims["exp"].(float64)) - now; expiresIn < 599 || expiresIn > 600 {
+		t.Fatalf("expected the token to expire in 600 seconds, expires in %d", expiresIn)
+	}
+
+	token, err = GenerateWithExpiry(jwt.MapClaims{"sub": "someone"}, time.Minute)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	claims, err = ParseTokenDefault(token)
+	if err != nil {
+		t.Fatalf("error parsing token ->> %s", err)
+	}
+	if expiresIn := int64(claims.(jwt.MapClaims)["exp"].(float64)) - now; expiresIn < 59 || expiresIn > 60 {
+		t.Fatalf("expected the token to expire in 60 seconds, expires in %d", expiresIn)
+	}
+
+	t.Setenv(authenv.TokenExpirationKey, "forever")
+	if _, err := GenerateWithDefault(jwt.MapClaims{"sub": "someone"}); err == nil {
+		t.Fatal("expected error for an invalid expiration time")
+	}
+}
+
+func TestVerifierFilterHeader(t *testing.T) {
+	issuer, err := NewIssuer(WithKey(tokenKey), WithHeader("X-Api-Token"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	token, err := issuer.Generate(randomMiniClaims())
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	handler := issuer.Verifier().Filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("X-Api-Token", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected %d found %d", http.StatusNoContent, recorder.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	issuer, err := NewIssuer(WithKey([]byte("replaced-default-key")))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	SetDefault(issuer, nil)
+	defer SetDefault(nil, nil)
+
+	token, err := GenerateWithDefault(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !IsValid("Bearer "+token, []byte("replaced-default-key")) {
+		t.Fatal("expected token to be signed with the replaced default key")
+	}
+	if !IsValidDefault("Bearer " + token) {
+		t.Fatal("expected token to be valid for the replaced default verifier")
+	}
+}
+
+func TestVerifierRejectsOtherAlgorithm(t *testing.T) {
+	verifier, err := NewVerifier(WithKey(tokenKey), WithAlgorithm("HS512"))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	token, err := Generate("HS256", randomMiniClaims(), tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	_, err = verifier.Parse(token)
+	if !errors.Is(err, ErrAlgorithmNotAllowed) {
+		t.Fatalf("expected ErrAlgorithmNotAllowed found %v", err)
+	}
+
+	expired := randomMiniClaims()
+	expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	token, err = Generate("HS512", expired, tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	_, err = verifier.Parse(token)
+	if err == nil || errors.Is(err, ErrAlgorithmNotAllowed) {
+		t.Fatalf("expected an expiration error found %v", err)
+	}
+}
+
+func TestVerifierRejectsNone(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, randomMiniClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	verifier, _ := NewVerifier(WithKey(tokenKey))
+	if _, err := verifier.Parse(token); !errors.Is(err, ErrAlgorithmNotAllowed) {
+		t.Fatalf("expected ErrAlgorithmNotAllowed found %v", err)
+	}
+	if _, err := NewVerifier(WithKey(tokenKey), WithAllowedAlgorithms("none")); err == nil {
+		t.Fatal("expected error allowing unsecured tokens")
+	}
+}
+
+func TestVerifierRejectsKeyConfusion(t *testing.T) {
+	_, publicKey := generatePEMKeyPair(t, "RS256")
+	verifier, err := NewVerifier(WithKey(publicKey), WithAlgorithm("RS256"))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+
+	// Sign with the public key as HMAC secret, as an attacker would.
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, randomMiniClaims()).SignedString(publicKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if _, err := verifier.Parse(token); !errors.Is(err, ErrAlgorithmNotAllowed) {
+		t.Fatalf("expected ErrAlgorithmNotAllowed found %v", err)
+	}
+	var algorithmErr *AlgorithmError
+	if _, err := verifier.Parse(token); !errors.As(err, &algorithmErr) || algorithmErr.Algorithm != "HS256" {
+		t.Fatalf("expected AlgorithmError for HS256 found %v", err)
+	}
+}
+
+func TestVerifierAudience(t *testing.T) {
+	orders, err := NewIssuer(WithKey(tokenKey), WithIssuers("https://auth.example.com"), WithAudiences("orders"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	billing, err := NewVerifier(WithKey(tokenKey), WithIssuers("https://auth.example.com"), WithAudiences("billing"))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	token, err := orders.Generate(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+
+	if _, err := orders.Verifier().Parse(token); err != nil {
+		t.Fatalf("expected the token to be valid for its audience ->> %s", err)
+	}
+	if _, err := billing.Parse(token); !errors.Is(err, ErrAudienceMismatch) {
+		t.Fatalf("expected ErrAudienceMismatch found %v", err)
+	}
+	if _, err := ParseToken(token, tokenKey, WithIssuers("https://other.example.com")); !errors.Is(err, ErrIssuerMismatch) {
+		t.Fatalf("expected ErrIssuerMismatch found %v", err)
+	}
+	if !IsValid(token, tokenKey, WithAudiences("billing", "orders")) {
+		t.Fatal("expected the token to be valid for any of the audiences")
+	}
+}
+
+func TestVerifierAudienceEnv(t *testing.T) {
+	t.Setenv(authenv.SigningMethodEnvKey, "HS512")
+	t.Setenv(authenv.TokenEnvKey, "audience-default-key")
+	t.Setenv(authenv.AudienceEnvKey, "orders")
+
+	token, err := GenerateWithDefault(jwt.MapClaims{"sub": "someone"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !IsValidDefault(token) {
+		t.Fatal("expected the token to be stamped with the audience in the os env")
+	}
+	token, err = GenerateWithDefault(jwt.MapClaims{"sub": "someone", "aud": "billing"})
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if IsValidDefault(token) {
+		t.Fatal("expected the token for another audience to be invalid")
+	}
+}
+
+func TestVerifierRequiredClaimsAndMaxAge(t *testing.T) {
+	verifier, err := NewVerifier(WithKey(tokenKey), WithRequiredClaims("sub"), WithMaxAge(time.Hour), WithLeeway(time.Minute))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	token, err := Generate("HS512", jwt.MapClaims{"scope": "read"}, tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	var claimErr *ClaimError
+	if _, err := verifier.Parse(token); !errors.As(err, &claimErr) || claimErr.Claim != "sub" {
+		t.Fatalf("expected a missing sub found %v", err)
+	}
+
+	issuedAt := time.Now().Add(-2 * time.Hour).Unix()
+	token, err = Generate("HS512", jwt.MapClaims{"sub": "someone", "iat": issuedAt, "nbf": issuedAt}, tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if verifier.IsValid(token) {
+		t.Fatal("expected the token older than the max age to be invalid")
+	}
+	token, err = Generate("HS512", jwt.MapClaims{"sub": "someone"}, tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !verifier.IsValid(token) {
+		t.Fatal("expected a fresh token to be valid")
+	}
+}
+
+func TestVerifierTokenUse(t *testing.T) {
+	idToken, err := Generate("HS512", jwt.MapClaims{"sub": "someone", "token_use": TokenUseID}, tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	verifier, _ := NewVerifier(WithKey(tokenKey))
+	var claimErr *ClaimError
+	if _, err := verifier.Parse(idToken); !errors.As(err, &claimErr) || claimErr.Claim != "token_use" {
+		t.Fatalf("expected the ID token to be refused found %v", err)
+	}
+	idVerifier, _ := NewVerifier(WithKey(tokenKey), WithTokenUse(TokenUseID))
+	if !idVerifier.IsValid(idToken) {
+		t.Fatal("expected the ID token to be valid for a verifier of ID tokens")
+	}
+	token, err := Generate("HS512", randomMiniClaims(), tokenKey)
+	if err != nil {
+		t.Fatalf("error while creating token ->> %s", err)
+	}
+	if !verifier.IsValid(token) {
+		t.Fatal("expected tokens without token_use to be valid")
+	}
+}
+
+func TestGenerateStampsClaimsWithIssuedAt(t *testing.T) {
+	t.Setenv(authenv.SigningMethodEnvKey, "HS512")
+	t.Setenv(authenv.TokenEnvKey, "stamp-default-key")
+	t.Setenv(authenv.TokenExpirationKey, "600")
+
+	// Claims carrying their own iat still get jti, nbf and exp, whatever the entry point.
+	issuedAt := time.Now().Unix()
+	claims := func() jwt.Claims {
+		return &MiniClaims{StandardClaims: jwt.StandardClaims{IssuedAt: issuedAt, Subject: "someone"}}
+	}
+	generators := map[string]func() (string, error){
+		"Generate":            func() (string, error) { return Generate("HS512", claims(), []byte("stamp-default-key")) },
+		"GenerateWithDefault": func() (string, error) { return GenerateWithDefault(claims()) },
+		"GenerateWithExpiry":  func() (string, error) { return GenerateWithExpiry(claims(), 10*time.Minute) },
+	}
+	for name, generate := range generators {
+		token, err := generate()
+		if err != nil {
+			t.Fatalf("%s: error while creating token ->> %s", name, err)
+		}
+		parsed, err := ParseTokenDefault(token)
+		if err != nil {
+			t.Fatalf("%s: error parsing token ->> %s", name, err)
+		}
+		mapClaims := parsed.(jwt.MapClaims)
+		if jti, _ := mapClaims["jti"].(string); jti == "" {
+			t.Fatalf("%s: expected a jti found %v", name, mapClaims)
+		}
+		if mapClaims["iat"] != float64(issuedAt) {
+			t.Fatalf("%s: expected the iat of the claims found %v", name, mapClaims["iat"])
+		}
+		if _, found := mapClaims["nbf"]; !found {
+			t.Fatalf("%s: expected a nbf found %v", name, mapClaims)
+		}
+		if expiresIn := int64(mapClaims["exp"].(float64)) - issuedAt; expiresIn < 599 || expiresIn > 601 {
+			t.Fatalf("%s: expected the token to expire in 600 seconds, expires in %d", name, expiresIn)
+		}
+	}
+}
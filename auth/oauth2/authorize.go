@@ -0,0 +1,204 @@
+package oauth2
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error codes of RFC 6749 section 4.1.2.1, in addition to the ones shared with the token endpoint.
+const (
+	ErrorAccessDenied            = "access_denied"
+	ErrorUnsupportedResponseType = "unsupported_response_type"
+)
+
+// AuthorizationRequest is a validated request of the authorize endpoint, as shown to the user for consent.
+type AuthorizationRequest struct {
+	// Client asking for authorization.
+	Client Client
+	// Subject of the signed in user.
+	Subject string
+	// Scopes the client asks for.
+	Scopes []string
+	// RedirectURI the response is sent to.
+	RedirectURI string
+	// State the client passed, it is handed back untouched.
+	State string
+	// Nonce the client passed for the ID token, empty unless it asked for OpenID Connect.
+	Nonce string
+}
+
+// Authentication describes the user signed in with the authorization server.
+type Authentication struct {
+	// Subject identifying the user.
+	Subject string
+	// Time the user authenticated, now when zero.
+	Time time.Time
+	// ACR is the authentication context class reference put in ID tokens, such as
+	// "mfa" when the user passed a second factor, left out when empty.
+	ACR string
+}
+
+// UserAuthenticator returns the user signed in with the authorization server. When there
+// is none it writes a response of its own, such as a redirect to the login page that
+// comes back to the same authorize URL, and returns false.
+type UserAuthenticator func(w http.ResponseWriter, r *http.Request) (authentication Authentication, ok bool)
+
+// Consent is what the user decided on an authorization request.
+type Consent struct {
+	// Granted is false when the user refused the client.
+	Granted bool
+	// Scopes the user granted, which may be fewer than requested.
+	Scopes []string
+}
+
+// ConsentFunc asks the user whether the client may act on their behalf. Until the user
+// decided it writes a response of its own, such as a consent page posting back to the
+// same authorize URL, and returns false.
+type ConsentFunc func(w http.ResponseWriter, r *http.Request, request AuthorizationRequest) (consent Consent, ok bool)
+
+// AuthorizeHandler is the authorize endpoint of the authorization_code grant, to be mounted at
+// a path such as /oauth/authorize. Clients have to use PKCE with the S256 method, and the
+// redirect_uri has to match one the client registered exactly. Once the user authenticator
+// and the consent callback let the request through, the user agent is redirected back to
+// the client with a single use authorization code and the state of the request.
+func (s *Server) AuthorizeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			writeError(w, newError(http.StatusMethodNotAllowed, ErrorInvalidRequest, "the authorize endpoint only accepts GET and POST"))
+			return
+		}
+		if s.authenticateUser == nil {
+			writeError(w, newError(http.StatusInternalServerError, ErrorServerError, "no user authenticator is configured"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if err := r.ParseForm(); err != nil {
+			writeError(w, newError(http.StatusBadRequest, ErrorInvalidRequest, "the request could not be read"))
+			return
+		}
+
+		// Until the client and its redirect URI are known to be right, errors are
+		// shown here rather than redirected, as the redirect could go anywhere.
+		client, err := s.clients.Client(r.Form.Get("client_id"))
+		if err != nil {
+			writeError(w, newError(http.StatusBadRequest, ErrorInvalidClient, "unknown client"))
+			return
+		}
+		redirectURI, ok := matchRedirectURI(client, r.Form.Get("redirect_uri"))
+		if !ok {
+			writeError(w, newError(http.StatusBadRequest, ErrorInvalidRequest, "the redirect_uri is not registered for the client"))
+			return
+		}
+		state := r.Form.Get("state")
+		fail := func(code string, description string) {
+			redirect(w, r, redirectURI, url.Values{"error": {code}, "error_description": {description}, "state": {state}})
+		}
+
+		if r.Form.Get("response_type") != "code" {
+			fail(ErrorUnsupportedResponseType, "only the code response type is supported")
+			return
+		}
+		if !client.Allows(GrantTypeAuthorizationCode) {
+			fail(ErrorUnauthorizedClient, "the client may not use the authorization_code grant")
+			return
+		}
+		challenge := r.Form.Get("code_challenge")
+		if r.Form.Get("code_challenge_method") != CodeChallengeMethodS256 || !validPKCEValue(challenge) {
+			fail(ErrorInvalidRequest, "a code_challenge with the S256 method is required")
+			return
+		}
+		scopes, oauthErr := grantScopes(client, r.Form.Get("scope"))
+		if oauthErr != nil {
+			fail(oauthErr.Code, oauthErr.Description)
+			return
+		}
+
+		authentication, ok := s.authenticateUser(w, r)
+		if !ok {
+			return
+		}
+		if authentication.Time.IsZero() {
+			authentication.Time = time.Now()
+		}
+		request := AuthorizationRequest{
+			Client:      client,
+			Subject:     authentication.Subject,
+			Scopes:      scopes,
+			RedirectURI: redirectURI,
+			State:       state,
+			Nonce:       r.Form.Get("nonce"),
+		}
+		consent := Consent{Granted: true, Scopes: scopes}
+		if s.consent != nil {
+			if consent, ok = s.consent(w, r, request); !ok {
+				return
+			}
+		}
+		if !consent.Granted {
+			fail(ErrorAccessDenied, "the user denied the request")
+			return
+		}
+		for _, scope := range consent.Scopes {
+			if !contains(scopes, scope) {
+				fail(ErrorInvalidScope, "the consent granted a scope that was not requested")
+				return
+			}
+		}
+
+		code, err := randomString(32)
+		if err == nil {
+			now := time.Now()
+			err = s.codes.SaveCode(AuthorizationCode{
+				Hash:          hashCode(code),
+				ClientID:      client.ID,
+				RedirectURI:   r.Form.Get("redirect_uri"),
+				Subject:       authentication.Subject,
+				Scopes:        consent.Scopes,
+				CodeChallenge: challenge,
+				AuthTime:      authentication.Time,
+				ACR:           authentication.ACR,
+				Nonce:         request.Nonce,
+				ExpiresAt:     now.Add(s.codeLifetime),
+			})
+		}
+		if err != nil {
+			fail(ErrorServerError, "the authorization code could not be issued")
+			return
+		}
+		redirect(w, r, redirectURI, url.Values{"code": {code}, "state": {state}})
+	})
+}
+
+// matchRedirectURI returns the redirect URI of the request when it is registered for the client
+// as is, or the registered one when the request leaves it out and the client has a single one.
+func matchRedirectURI(client Client, redirectURI string) (string, bool) {
+	if redirectURI == "" {
+		if len(client.RedirectURIs) == 1 {
+			return client.RedirectURIs[0], true
+		}
+		return "", false
+	}
+	return redirectURI, contains(client.RedirectURIs, redirectURI)
+}
+
+// redirect sends the user agent back to the client with the given parameters added to the
+// query of the redirect URI, empty ones left out.
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		writeError(w, newError(http.StatusBadRequest, ErrorInvalidRequest, "the redirect_uri is invalid"))
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		if len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+			query.Set(key, values[0])
+		}
+	}
+	target.RawQuery = query.Encode()
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
@@ -0,0 +1,242 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	testRedirect  = "https://app.example.com/callback"
+)
+
+func newAuthorizeServer(t *testing.T, opts ...Option) (*Server, Client) {
+	issuer, err := jwtauth.NewIssuer(jwtauth.WithKey(tokenKey), jwtauth.WithAlgorithm("HS256"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	store := NewMemoryClientStore()
+	client, _, err := RegisterClient(store, Client{
+		ID:           "spa",
+		Scopes:       []string{"openid", "orders:read", "orders:write"},
+		GrantTypes:   []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken},
+		RedirectURIs: []string{testRedirect, "https://app.example.com/other"},
+	}, true)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	authenticate := func(w http.ResponseWriter, r *http.Request) (Authentication, bool) {
+		if r.Header.Get("X-User") == "" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return Authentication{}, false
+		}
+		return Authentication{Subject: r.Header.Get("X-User"), ACR: "pwd"}, true
+	}
+	server, err := NewServer(issuer, store, append([]Option{WithUserAuthenticator(authenticate)}, opts...)...)
+	if err != nil {
+		t.Fatalf("error creating server ->> %s", err)
+	}
+	return server, client
+}
+
+func authorize(server *Server, query url.Values, user string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	if user != "" {
+		request.Header.Set("X-User", user)
+	}
+	recorder := httptest.NewRecorder()
+	server.AuthorizeHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func authorizeQuery() url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"spa"},
+		"redirect_uri":          {testRedirect},
+		"scope":                 {"orders:read"},
+		"state":                 {"xyz"},
+		"code_challenge":        {testChallenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func redirectQuery(t *testing.T, recorder *httptest.ResponseRecorder) url.Values {
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("expected %d found %d: %s", http.StatusFound, recorder.Code, recorder.Body)
+	}
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("error parsing redirect ->> %s", err)
+	}
+	if location.Scheme+"://"+location.Host+location.Path != testRedirect {
+		t.Fatalf("unexpected redirect %s", location)
+	}
+	return location.Query()
+}
+
+func TestAuthorizationCodeGrant(t *testing.T) {
+	server, client := newAuthorizeServer(t)
+	if recorder := authorize(server, authorizeQuery(), ""); recorder.Header().Get("Location") != "/login" {
+		t.Fatalf("expected a redirect to the login page, found %d %s", recorder.Code, recorder.Header().Get("Location"))
+	}
+	query := redirectQuery(t, authorize(server, authorizeQuery(), "alice"))
+	if query.Get("state") != "xyz" || query.Get("code") == "" {
+		t.Fatalf("expected a code and the state, found %v", query)
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {client.ID},
+		"code":          {query.Get("code")},
+		"redirect_uri":  {testRedirect},
+		"code_verifier": {testVerifier},
+	}
+	recorder := postToken(server, form, "", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var response TokenResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("error decoding token response ->> %s", err)
+	}
+	claims, err := jwtauth.ParseToken(response.AccessToken, tokenKey, jwtauth.WithAlgorithm("HS256"))
+	if err != nil {
+		t.Fatalf("error parsing access token ->> %s", err)
+	}
+	if mapClaims := claims.(jwt.MapClaims); mapClaims["sub"] != "alice" || mapClaims["client_id"] != "spa" || mapClaims["scope"] != "orders:read" {
+		t.Fatalf("unexpected access token claims %v", mapClaims)
+	}
+	if response.RefreshToken == "" || response.Scope != "orders:read" {
+		t.Fatalf("unexpected token response %+v", response)
+	}
+
+	// The code is single use.
+	if recorder := postToken(server, form, "", ""); decodeError(t, recorder) != ErrorInvalidGrant {
+		t.Fatal("expected a used code to be refused")
+	}
+
+	refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {client.ID}, "refresh_token": {response.RefreshToken}}
+	recorder = postToken(server, refresh, "", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var refreshed TokenResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&refreshed); err != nil {
+		t.Fatalf("error decoding token response ->> %s", err)
+	}
+	if refreshed.RefreshToken == response.RefreshToken || refreshed.Scope != "orders:read" {
+		t.Fatalf("expected a rotated refresh token, found %+v", refreshed)
+	}
+	if recorder := postToken(server, refresh, "", ""); decodeError(t, recorder) != ErrorInvalidGrant {
+		t.Fatal("expected a rotated out refresh token to be refused")
+	}
+}
+
+func TestAuthorizationCodeMismatch(t *testing.T) {
+	server, client := newAuthorizeServer(t)
+	tests := []struct {
+		name  string
+		field string
+		value string
+	}{
+		{"verifier", "code_verifier", testVerifier[1:] + "a"},
+		{"redirect uri", "redirect_uri", "https://app.example.com/other"},
+		{"client", "client_id", "other"},
+	}
+	for _, test := range tests {
+		query := redirectQuery(t, authorize(server, authorizeQuery(), "alice"))
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {client.ID},
+			"code":          {query.Get("code")},
+			"redirect_uri":  {testRedirect},
+			"code_verifier": {testVerifier},
+		}
+		form.Set(test.field, test.value)
+		recorder := postToken(server, form, "", "")
+		if recorder.Code == http.StatusOK {
+			t.Fatalf("%s: expected the code to be refused", test.name)
+		}
+	}
+}
+
+func TestRefreshTokenOfAnotherClient(t *testing.T) {
+	server, client := newAuthorizeServer(t)
+	other, _, err := RegisterClient(server.clients, Client{
+		ID:         "other",
+		Scopes:     []string{"orders:read"},
+		GrantTypes: []string{GrantTypeRefreshToken},
+	}, true)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	response := exchangeCode(t, server, authorizeQuery())
+
+	refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {other.ID}, "refresh_token": {response.RefreshToken}}
+	if recorder := postToken(server, refresh, "", ""); decodeError(t, recorder) != ErrorInvalidGrant {
+		t.Fatal("expected the refresh token of another client to be refused")
+	}
+	// The refresh token leaked, so its family is revoked for the client it was issued to as well.
+	refresh.Set("client_id", client.ID)
+	if recorder := postToken(server, refresh, "", ""); decodeError(t, recorder) != ErrorInvalidGrant {
+		t.Fatal("expected the refresh token to be revoked")
+	}
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	server, _ := newAuthorizeServer(t)
+	// Without a trusted redirect URI the error is answered directly.
+	for _, field := range []string{"client_id", "redirect_uri"} {
+		query := authorizeQuery()
+		query.Set(field, "https://evil.example.com")
+		if recorder := authorize(server, query, "alice"); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected %d found %d", field, http.StatusBadRequest, recorder.Code)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		value string
+		code  string
+	}{
+		{"response type", "response_type", "token", ErrorUnsupportedResponseType},
+		{"no challenge", "code_challenge", "", ErrorInvalidRequest},
+		{"plain challenge", "code_challenge_method", "plain", ErrorInvalidRequest},
+		{"scope", "scope", "admin", ErrorInvalidScope},
+	}
+	for _, test := range tests {
+		query := authorizeQuery()
+		query.Set(test.field, test.value)
+		response := redirectQuery(t, authorize(server, query, "alice"))
+		if response.Get("error") != test.code || response.Get("state") != "xyz" {
+			t.Fatalf("%s: expected %s found %v", test.name, test.code, response)
+		}
+	}
+}
+
+func TestAuthorizeConsent(t *testing.T) {
+	var asked AuthorizationRequest
+	consent := func(w http.ResponseWriter, r *http.Request, request AuthorizationRequest) (Consent, bool) {
+		asked = request
+		return Consent{Granted: r.URL.Query().Get("deny") == ""}, true
+	}
+	server, _ := newAuthorizeServer(t, WithConsent(consent))
+	query := authorizeQuery()
+	query.Set("deny", "1")
+	response := redirectQuery(t, authorize(server, query, "alice"))
+	if response.Get("error") != ErrorAccessDenied || response.Get("code") != "" {
+		t.Fatalf("expected access_denied, found %v", response)
+	}
+	if asked.Subject != "alice" || asked.Client.ID != "spa" || asked.State != "xyz" {
+		t.Fatalf("unexpected consent request %+v", asked)
+	}
+}
@@ -0,0 +1,157 @@
+// Package oauth2 is an OAuth 2.0 authorization server (RFC 6749) issuing its access
+// tokens as jwt through jwtauth.
+package oauth2
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Grant types of RFC 6749.
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// ErrClientNotFound is returned by client stores for ids they hold no client for.
+var ErrClientNotFound = errors.New("client not found")
+
+// Client is an application registered with the authorization server.
+type Client struct {
+	// ID identifies the client, it is not a secret.
+	ID string `json:"client_id"`
+	// SecretHash is the hash of the client secret, see HashSecret. Public clients have none.
+	SecretHash string `json:"secret_hash,omitempty"`
+	// Name of the client, for people.
+	Name string `json:"name,omitempty"`
+	// Scopes the client may request.
+	Scopes []string `json:"scopes,omitempty"`
+	// GrantTypes the client may use.
+	GrantTypes []string `json:"grant_types,omitempty"`
+	// RedirectURIs the client may have authorization responses sent to.
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+}
+
+// Public reports whether the client has no secret, such as a single page or mobile app.
+func (c *Client) Public() bool {
+	return c.SecretHash == ""
+}
+
+// Allows reports whether the client may use the given grant type.
+func (c *Client) Allows(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+// ClientStore keeps the registered clients, implementations must be safe for concurrent use.
+type ClientStore interface {
+	// Client returns the client with the given id, ErrClientNotFound when there is none.
+	Client(id string) (Client, error)
+	// SaveClient stores the client, replacing the one with the same id.
+	SaveClient(client Client) error
+}
+
+// MemoryClientStore keeps the registered clients in memory.
+type MemoryClientStore struct {
+	mu      sync.RWMutex
+	clients map[string]Client
+}
+
+// NewMemoryClientStore creates an empty in memory client store.
+func NewMemoryClientStore() *MemoryClientStore {
+	return &MemoryClientStore{clients: map[string]Client{}}
+}
+
+// Client returns the client with the given id.
+func (s *MemoryClientStore) Client(id string) (Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, found := s.clients[id]
+	if !found {
+		return Client{}, ErrClientNotFound
+	}
+	return client, nil
+}
+
+// SaveClient stores the client.
+func (s *MemoryClientStore) SaveClient(client Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[client.ID] = client
+	return nil
+}
+
+// RegisterClient stores the given client with a new id, unless it has one, and with a new
+// secret unless public is set. The secret is returned only this once, the store keeps its hash.
+func RegisterClient(store ClientStore, client Client, public bool) (registered Client, secret string, err error) {
+	if client.ID == "" {
+		client.ID = uuid.NewString()
+	}
+	client.SecretHash = ""
+	if !public {
+		if secret, err = randomString(32); err != nil {
+			return Client{}, "", err
+		}
+		if client.SecretHash, err = HashSecret(secret); err != nil {
+			return Client{}, "", err
+		}
+	}
+	if err := store.SaveClient(client); err != nil {
+		return Client{}, "", err
+	}
+	return client, secret, nil
+}
+
+// HashSecret returns a salted hash of the client secret. Secrets made by RegisterClient are
+// random enough for a single round of SHA-256, secrets chosen by people are not.
+func HashSecret(secret string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append(salt, secret...))
+	return "sha256$" + hex.EncodeToString(salt) + "$" + hex.EncodeToString(sum[:]), nil
+}
+
+// VerifySecret reports whether the secret matches the hash made by HashSecret.
+func VerifySecret(hash string, secret string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 3 || parts[0] != "sha256" {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	expected, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(append(salt, secret...))
+	return subtle.ConstantTimeCompare(sum[:], expected) == 1
+}
+
+func randomString(size int) (string, error) {
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package oauth2
+
+import "testing"
+
+func TestRegisterClient(t *testing.T) {
+	store := NewMemoryClientStore()
+	client, secret, err := RegisterClient(store, Client{Name: "reports", Scopes: []string{"orders:read"}}, false)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	if client.ID == "" || secret == "" {
+		t.Fatal("expected the client to get an id and a secret")
+	}
+	stored, err := store.Client(client.ID)
+	if err != nil {
+		t.Fatalf("error loading client ->> %s", err)
+	}
+	if stored.SecretHash == secret || !VerifySecret(stored.SecretHash, secret) {
+		t.Fatal("expected the store to keep a hash matching the secret")
+	}
+	if VerifySecret(stored.SecretHash, secret+"x") {
+		t.Fatal("expected another secret not to match")
+	}
+
+	public, secret, err := RegisterClient(store, Client{ID: "spa"}, true)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	if secret != "" || !public.Public() {
+		t.Fatal("expected a public client without secret")
+	}
+}
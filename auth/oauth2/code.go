@@ -0,0 +1,127 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultCodeLifetime is how long authorization codes can be exchanged, RFC 6749
+// recommends ten minutes at most.
+const defaultCodeLifetime = 1 * time.Minute
+
+// CodeChallengeMethodS256 is the only PKCE method accepted, plain would let
+// an intercepted authorization request be enough to redeem the code.
+const CodeChallengeMethodS256 = "S256"
+
+// ErrCodeNotFound is returned by code stores for codes they hold no record of, or no longer.
+var ErrCodeNotFound = errors.New("authorization code not found")
+
+// AuthorizationCode is what a CodeStore keeps of an authorization code between the
+// authorize and the token endpoint, the code itself is never stored.
+type AuthorizationCode struct {
+	// Hash of the code.
+	Hash string
+	// ClientID of the client the code was issued to.
+	ClientID string
+	// RedirectURI given in the authorization request, empty when it was left out.
+	RedirectURI string
+	// Subject of the user who authorized the client.
+	Subject string
+	// Scopes the user granted.
+	Scopes []string
+	// CodeChallenge of PKCE, the S256 hash of the code verifier.
+	CodeChallenge string
+	// AuthTime is when the user authenticated.
+	AuthTime time.Time
+	// ACR is the authentication context class reference of the authentication.
+	ACR string
+	// Nonce of the authorization request, put in the ID token.
+	Nonce string
+	// ExpiresAt is when the code can no longer be exchanged.
+	ExpiresAt time.Time
+}
+
+// CodeStore keeps the authorization codes, implementations must be safe for concurrent use.
+type CodeStore interface {
+	// SaveCode stores the code.
+	SaveCode(code AuthorizationCode) error
+	// TakeCode returns and removes the code with the given hash in one step, so that a code
+	// is exchanged once at most. ErrCodeNotFound when there is none.
+	TakeCode(hash string) (AuthorizationCode, error)
+}
+
+// MemoryCodeStore keeps the authorization codes in memory.
+type MemoryCodeStore struct {
+	mu    sync.Mutex
+	codes map[string]AuthorizationCode
+}
+
+// NewMemoryCodeStore creates an empty in memory code store.
+func NewMemoryCodeStore() *MemoryCodeStore {
+	return &MemoryCodeStore{codes: map[string]AuthorizationCode{}}
+}
+
+// SaveCode stores the code and drops the expired ones.
+func (s *MemoryCodeStore) SaveCode(code AuthorizationCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for hash, stored := range s.codes {
+		if now.After(stored.ExpiresAt) {
+			delete(s.codes, hash)
+		}
+	}
+	s.codes[code.Hash] = code
+	return nil
+}
+
+// TakeCode returns and removes the code with the given hash.
+func (s *MemoryCodeStore) TakeCode(hash string) (AuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, found := s.codes[hash]
+	if !found {
+		return AuthorizationCode{}, ErrCodeNotFound
+	}
+	delete(s.codes, hash)
+	return code, nil
+}
+
+// hashCode returns the hash code stores keep in place of the code.
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// validPKCEValue reports whether the code verifier or challenge has the length and
+// characters RFC 7636 section 4.1 allows.
+func validPKCEValue(value string) bool {
+	if len(value) < 43 || len(value) > 128 {
+		return false
+	}
+	for _, c := range value {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// verifyCodeChallenge reports whether the S256 hash of the verifier is the challenge.
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if !validPKCEValue(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
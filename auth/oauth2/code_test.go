@@ -0,0 +1,36 @@
+package oauth2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCodeStore(t *testing.T) {
+	store := NewMemoryCodeStore()
+	code := AuthorizationCode{Hash: hashCode("code"), ClientID: "spa", ExpiresAt: time.Now().Add(time.Minute)}
+	if err := store.SaveCode(code); err != nil {
+		t.Fatalf("error saving code ->> %s", err)
+	}
+	taken, err := store.TakeCode(hashCode("code"))
+	if err != nil || taken.ClientID != "spa" {
+		t.Fatalf("expected the saved code, found %+v ->> %v", taken, err)
+	}
+	if _, err := store.TakeCode(hashCode("code")); err != ErrCodeNotFound {
+		t.Fatalf("expected a code to be taken once, found %v", err)
+	}
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// The example of RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if !verifyCodeChallenge(challenge, verifier) {
+		t.Fatal("expected the verifier to match the challenge")
+	}
+	if verifyCodeChallenge(challenge, verifier[1:]+"a") {
+		t.Fatal("expected another verifier not to match")
+	}
+	if verifyCodeChallenge(challenge, "short") {
+		t.Fatal("expected a too short verifier to be refused")
+	}
+}
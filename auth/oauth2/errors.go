@@ -0,0 +1,56 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Error codes of RFC 6749 section 5.2.
+const (
+	ErrorInvalidRequest       = "invalid_request"
+	ErrorInvalidClient        = "invalid_client"
+	ErrorInvalidGrant         = "invalid_grant"
+	ErrorUnauthorizedClient   = "unauthorized_client"
+	ErrorUnsupportedGrantType = "unsupported_grant_type"
+	ErrorInvalidScope         = "invalid_scope"
+	ErrorServerError          = "server_error"
+)
+
+// Error is an OAuth 2.0 error response.
+type Error struct {
+	// Status is the HTTP status code of the response.
+	Status int `json:"-"`
+	// Code is one of the Error constants.
+	Code string `json:"error"`
+	// Description is a human readable explanation for the client developer.
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func newError(status int, code string, description string) *Error {
+	return &Error{Status: status, Code: code, Description: description}
+}
+
+// writeError answers with the JSON body of RFC 6749 section 5.2.
+func writeError(w http.ResponseWriter, oauthErr *Error) {
+	if oauthErr.Code == ErrorInvalidClient && oauthErr.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+	}
+	writeJSON(w, oauthErr.Status, oauthErr)
+}
+
+// writeJSON answers with the given body, never to be cached as it carries tokens or errors about them.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
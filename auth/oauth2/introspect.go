@@ -0,0 +1,163 @@
+package oauth2
+
+import (
+	"net/http"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/bellomd/miniauth/auth/opaquetoken"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// Token type hints of RFC 7009 section 2.1, also used by introspection.
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// tokenInfo is what the server knows of a token it accepts.
+type tokenInfo struct {
+	claims    jwt.MapClaims
+	tokenType string
+	clientID  string
+	revoke    func() error
+}
+
+// IntrospectionHandler is the introspection endpoint of RFC 7662, to be mounted at a path such
+// as /oauth/introspect, for resource servers that cannot validate tokens themselves. They
+// authenticate as confidential clients and post the token, which is answered with its claims
+// along with "active": true, or with "active": false alone for a token that is unknown,
+// expired or revoked. Access tokens of the server, its refresh tokens and, with
+// WithOpaqueTokens, opaque tokens are recognized, token_type_hint is not needed.
+func (s *Server) IntrospectionHandler() http.Handler {
+	return s.tokenEndpoint(func(w http.ResponseWriter, r *http.Request, client Client) {
+		if client.Public() {
+			writeError(w, newError(http.StatusUnauthorized, ErrorInvalidClient, "public clients may not introspect tokens"))
+			return
+		}
+		info, oauthErr := s.resolveToken(r.PostForm.Get("token"))
+		if oauthErr != nil {
+			writeError(w, oauthErr)
+			return
+		}
+		if info == nil {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"active": false})
+			return
+		}
+		response := map[string]interface{}{}
+		for key, value := range info.claims {
+			response[key] = value
+		}
+		response["active"] = true
+		if info.clientID != "" {
+			response["client_id"] = info.clientID
+		}
+		if info.tokenType != "" {
+			response["token_type"] = info.tokenType
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// RevocationHandler is the revocation endpoint of RFC 7009, to be mounted at a path such as
+// /oauth/revoke, for clients to revoke their own tokens on logout. A revoked access token is
+// put in the revocation store of the verifier, so DoFilter and the jwtauth validation functions
+// reject it from then on, a revoked refresh token takes its whole family along and a revoked
+// opaque token is removed from its store. Tokens issued to another client are never revoked,
+// RFC 7009 section 2.1, they are answered with 200 like unknown tokens so the caller cannot
+// tell them apart. The token type is recognized like introspection does, token_type_hint is
+// ignored whatever its value.
+func (s *Server) RevocationHandler() http.Handler {
+	return s.tokenEndpoint(func(w http.ResponseWriter, r *http.Request, client Client) {
+		info, oauthErr := s.resolveToken(r.PostForm.Get("token"))
+		if oauthErr != nil {
+			writeError(w, oauthErr)
+			return
+		}
+		if info != nil && info.clientID == client.ID {
+			if err := info.revoke(); err != nil {
+				writeError(w, newError(http.StatusServiceUnavailable, ErrorServerError, "the token could not be revoked"))
+				return
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// tokenEndpoint reads the form of a POST request and authenticates the client
+// before handing both over, like the token endpoint does.
+func (s *Server) tokenEndpoint(handle func(w http.ResponseWriter, r *http.Request, client Client)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, newError(http.StatusMethodNotAllowed, ErrorInvalidRequest, "the endpoint only accepts POST"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if err := r.ParseForm(); err != nil {
+			writeError(w, newError(http.StatusBadRequest, ErrorInvalidRequest, "the request body could not be read"))
+			return
+		}
+		client, oauthErr := s.authenticateClient(r)
+		if oauthErr != nil {
+			writeError(w, oauthErr)
+			return
+		}
+		if r.PostForm.Get("token") == "" {
+			writeError(w, newError(http.StatusBadRequest, ErrorInvalidRequest, "the token parameter is required"))
+			return
+		}
+		handle(w, r, client)
+	})
+}
+
+// resolveToken returns what the server knows of the given token, nil when it does not accept it.
+func (s *Server) resolveToken(token string) (*tokenInfo, *Error) {
+	if s.opaque != nil {
+		record, err := s.opaque.Validate(token)
+		switch {
+		case err == nil:
+			return &tokenInfo{
+				claims:    record.Claims(),
+				tokenType: "Bearer",
+				clientID:  record.Metadata["client_id"],
+				revoke:    func() error { return s.opaque.Revoke(token) },
+			}, nil
+		case err != opaquetoken.ErrMalformed && err != opaquetoken.ErrInvalid && err != opaquetoken.ErrExpired:
+			return nil, newError(http.StatusInternalServerError, ErrorServerError, "the token could not be loaded")
+		}
+	}
+
+	record, err := s.refresh.Lookup(token)
+	switch {
+	case err == nil:
+		clientID, _ := record.Claims["client_id"].(string)
+		claims := jwt.MapClaims{}
+		for key, value := range record.Claims {
+			claims[key] = value
+		}
+		claims["exp"] = record.ExpiresAt.Unix()
+		return &tokenInfo{
+			claims:    claims,
+			tokenType: TokenTypeHintRefreshToken,
+			clientID:  clientID,
+			revoke:    func() error { return s.refresh.Revoke(token) },
+		}, nil
+	case !errors.Is(err, jwtauth.ErrRefreshTokenInvalid):
+		return nil, newError(http.StatusInternalServerError, ErrorServerError, "the token could not be loaded")
+	}
+
+	claims, err := s.verifier.Parse(token)
+	if err != nil {
+		// Whatever makes the token unacceptable, it is simply inactive.
+		return nil, nil
+	}
+	clientID, _ := claims["client_id"].(string)
+	return &tokenInfo{
+		claims:    claims,
+		tokenType: "Bearer",
+		clientID:  clientID,
+		revoke:    func() error { return s.verifier.Revoke(token) },
+	}, nil
+}
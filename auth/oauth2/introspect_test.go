@@ -0,0 +1,173 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/bellomd/miniauth/auth/opaquetoken"
+)
+
+func postForm(handler http.Handler, form url.Values, clientID, secret string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/oauth/endpoint", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func introspect(t *testing.T, server *Server, token, clientID, secret string) map[string]interface{} {
+	recorder := postForm(server.IntrospectionHandler(), url.Values{"token": {token}}, clientID, secret)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	response := map[string]interface{}{}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("error decoding introspection response ->> %s", err)
+	}
+	return response
+}
+
+func issueClientToken(t *testing.T, server *Server, clientID, secret string) string {
+	recorder := postToken(server, url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}}, clientID, secret)
+	var response TokenResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("error decoding token response ->> %s", err)
+	}
+	return response.AccessToken
+}
+
+func TestIntrospectAndRevokeAccessToken(t *testing.T) {
+	server, client, secret := newTestServer(t)
+	token := issueClientToken(t, server, client.ID, secret)
+
+	response := introspect(t, server, token, client.ID, secret)
+	if response["active"] != true || response["scope"] != "orders:read" || response["client_id"] != client.ID || response["sub"] != client.ID {
+		t.Fatalf("unexpected introspection response %v", response)
+	}
+	if _, found := response["exp"]; !found {
+		t.Fatalf("expected exp in the introspection response %v", response)
+	}
+	if response := introspect(t, server, "not-a-token", client.ID, secret); len(response) != 1 || response["active"] != false {
+		t.Fatalf("expected an inactive token, found %v", response)
+	}
+
+	// Another client cannot revoke the token, but is not told so.
+	other, otherSecret, err := RegisterClient(server.clients, Client{GrantTypes: []string{GrantTypeClientCredentials}}, false)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	if recorder := postForm(server.RevocationHandler(), url.Values{"token": {token}}, other.ID, otherSecret); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d", http.StatusOK, recorder.Code)
+	}
+	if response := introspect(t, server, token, client.ID, secret); response["active"] != true {
+		t.Fatal("expected the token of another client to stay active")
+	}
+
+	if recorder := postForm(server.RevocationHandler(), url.Values{"token": {token}}, client.ID, secret); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d", http.StatusOK, recorder.Code)
+	}
+	if response := introspect(t, server, token, client.ID, secret); response["active"] != false {
+		t.Fatal("expected a revoked token to be inactive")
+	}
+	if err := jwtauth.Validate(token, tokenKey, jwtauth.WithAlgorithm("HS256")); !errors.Is(err, jwtauth.ErrRevoked) {
+		t.Fatalf("expected ErrRevoked found %v", err)
+	}
+}
+
+func TestIntrospectOpaqueToken(t *testing.T) {
+	manager, err := opaquetoken.NewManager("mat", opaquetoken.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("error creating manager ->> %s", err)
+	}
+	server, client, secret := newTestServer(t)
+	WithOpaqueTokens(manager)(server)
+	token, _, err := manager.Issue("someone", time.Hour, []string{"orders:read"}, map[string]string{"client_id": client.ID})
+	if err != nil {
+		t.Fatalf("error issuing token ->> %s", err)
+	}
+
+	response := introspect(t, server, token, client.ID, secret)
+	if response["active"] != true || response["sub"] != "someone" || response["scope"] != "orders:read" {
+		t.Fatalf("unexpected introspection response %v", response)
+	}
+	form := url.Values{"token": {token}, "token_type_hint": {TokenTypeHintAccessToken}}
+	if recorder := postForm(server.RevocationHandler(), form, client.ID, secret); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d", http.StatusOK, recorder.Code)
+	}
+	if _, err := manager.Validate(token); err != opaquetoken.ErrInvalid {
+		t.Fatalf("expected the opaque token to be revoked, found %v", err)
+	}
+}
+
+func TestIntrospectionErrors(t *testing.T) {
+	server, client, secret := newTestServer(t)
+	public, _, err := RegisterClient(server.clients, Client{}, true)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		form     url.Values
+		clientID string
+		secret   string
+		status   int
+		code     string
+	}{
+		{"wrong secret", server.IntrospectionHandler(), url.Values{"token": {"token"}}, client.ID, "wrong", http.StatusUnauthorized, ErrorInvalidClient},
+		{"public client", server.IntrospectionHandler(), url.Values{"token": {"token"}}, public.ID, "", http.StatusUnauthorized, ErrorInvalidClient},
+		{"no token", server.IntrospectionHandler(), url.Values{}, client.ID, secret, http.StatusBadRequest, ErrorInvalidRequest},
+	}
+	for _, test := range tests {
+		recorder := postForm(test.handler, test.form, test.clientID, test.secret)
+		if recorder.Code != test.status {
+			t.Fatalf("%s: expected %d found %d", test.name, test.status, recorder.Code)
+		}
+		if code := decodeError(t, recorder); code != test.code {
+			t.Fatalf("%s: expected %s found %s", test.name, test.code, code)
+		}
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	server, client := newAuthorizeServer(t)
+	query := redirectQuery(t, authorize(server, authorizeQuery(), "alice"))
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {client.ID},
+		"code":          {query.Get("code")},
+		"redirect_uri":  {testRedirect},
+		"code_verifier": {testVerifier},
+	}
+	var response TokenResponse
+	if err := json.NewDecoder(postToken(server, form, "", "").Body).Decode(&response); err != nil {
+		t.Fatalf("error decoding token response ->> %s", err)
+	}
+
+	resource, resourceSecret, err := RegisterClient(server.clients, Client{Name: "orders api"}, false)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	introspection := introspect(t, server, response.RefreshToken, resource.ID, resourceSecret)
+	if introspection["active"] != true || introspection["token_type"] != TokenTypeHintRefreshToken {
+		t.Fatalf("expected an active refresh token, found %v", introspection)
+	}
+
+	// Unrecognized hints are ignored, the refresh token is found all the same.
+	revoke := url.Values{"token": {response.RefreshToken}, "token_type_hint": {"id_token"}}
+	if recorder := postForm(server.RevocationHandler(), revoke, client.ID, ""); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {client.ID}, "refresh_token": {response.RefreshToken}}
+	if recorder := postToken(server, refresh, "", ""); decodeError(t, recorder) != ErrorInvalidGrant {
+		t.Fatal("expected a revoked refresh token to be refused")
+	}
+}
@@ -0,0 +1,251 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// Cache settings of an Introspector unless WithIntrospectionCache says otherwise.
+const (
+	defaultIntrospectionTTL         = 1 * time.Minute
+	defaultNegativeIntrospectionTTL = 10 * time.Second
+	maxIntrospectionCacheEntries    = 10000
+)
+
+// ErrTokenInactive is returned by Introspector.Introspect for tokens the authorization server does not accept.
+var ErrTokenInactive = errors.New("token is not active")
+
+// Introspector validates access tokens by asking the introspection endpoint of an authorization
+// server, RFC 7662, for resource servers receiving tokens they cannot validate themselves.
+// Answers are cached, so a revoked token may be accepted until its cache entry expires.
+type Introspector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	ttl          time.Duration
+	negativeTTL  time.Duration
+	extractor    jwtauth.Extractor
+	errorHandler jwtauth.ErrorHandler
+
+	mu    sync.Mutex
+	cache map[string]introspection
+}
+
+// introspection is a cached answer of the introspection endpoint, claims is nil for inactive tokens.
+type introspection struct {
+	claims    jwt.MapClaims
+	expiresAt time.Time
+}
+
+// IntrospectorOption configures an Introspector.
+type IntrospectorOption func(*Introspector)
+
+// WithHTTPClient sets the client the introspection endpoint is called with, by
+// default one giving up after ten seconds.
+func WithHTTPClient(client *http.Client) IntrospectorOption {
+	return func(i *Introspector) {
+		i.client = client
+	}
+}
+
+// WithIntrospectionCache sets how long active tokens are cached, one minute by default and never
+// past their exp, and how long inactive ones are, ten seconds by default. Zero turns caching off.
+func WithIntrospectionCache(ttl time.Duration, negativeTTL time.Duration) IntrospectorOption {
+	return func(i *Introspector) {
+		i.ttl, i.negativeTTL = ttl, negativeTTL
+	}
+}
+
+// WithIntrospectionExtractor sets how Filter reads the token from the request, by default
+// from the Authorization header with the Bearer scheme.
+func WithIntrospectionExtractor(extractor jwtauth.Extractor) IntrospectorOption {
+	return func(i *Introspector) {
+		i.extractor = extractor
+	}
+}
+
+// WithIntrospectionErrorHandler sets how Filter answers requests it refuses, jwtauth.BearerErrorHandler by default.
+func WithIntrospectionErrorHandler(errorHandler jwtauth.ErrorHandler) IntrospectorOption {
+	return func(i *Introspector) {
+		i.errorHandler = errorHandler
+	}
+}
+
+// NewIntrospector creates an introspector calling the given introspection endpoint,
+// authenticated with HTTP Basic as the given client.
+func NewIntrospector(endpoint string, clientID string, clientSecret string, opts ...IntrospectorOption) (*Introspector, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, errors.New("invalid introspection endpoint")
+	}
+	if clientID == "" {
+		return nil, errors.New("invalid client id")
+	}
+	i := &Introspector{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		ttl:          defaultIntrospectionTTL,
+		negativeTTL:  defaultNegativeIntrospectionTTL,
+		extractor:    jwtauth.BearerExtractor(authenv.AuthorizationHeader),
+		errorHandler: jwtauth.BearerErrorHandler,
+		cache:        map[string]introspection{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i, nil
+}
+
+// Introspect returns the claims of the given token as the authorization server describes them,
+// without the active member, ErrTokenInactive when the server does not accept the token.
+func (i *Introspector) Introspect(ctx context.Context, token string) (jwt.MapClaims, error) {
+	key := introspectionKey(token)
+	if claims, found := i.cached(key); found {
+		if claims == nil {
+			return nil, ErrTokenInactive
+		}
+		return claims, nil
+	}
+
+	claims, err := i.call(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if claims == nil {
+		i.store(key, introspection{expiresAt: now.Add(i.negativeTTL)})
+		return nil, ErrTokenInactive
+	}
+	expiresAt := now.Add(i.ttl)
+	if exp, ok := claims["exp"].(float64); ok {
+		if time.Unix(int64(exp), 0).Before(now) {
+			return nil, ErrTokenInactive
+		}
+		if time.Unix(int64(exp), 0).Before(expiresAt) {
+			expiresAt = time.Unix(int64(exp), 0)
+		}
+	}
+	i.store(key, introspection{claims: claims, expiresAt: expiresAt})
+	return claims, nil
+}
+
+// Filter check if the request has a token the authorization server accepts, its claims are
+// passed on in the request context for jwtauth.ClaimsFromContext, so jwtauth.RequireScopes
+// and the like work as with the jwt filters. Refused requests are answered like they do,
+// and with 503 when the introspection endpoint cannot be reached.
+func (i *Introspector) Filter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := i.extractor.Extract(r)
+		if err != nil {
+			i.errorHandler(w, r, jwtauth.NewAuthError(err))
+			return
+		}
+		claims, err := i.Introspect(r.Context(), token)
+		if err == ErrTokenInactive {
+			i.errorHandler(w, r, jwtauth.NewAuthError(err))
+			return
+		}
+		if err != nil {
+			i.errorHandler(w, r, &jwtauth.AuthError{
+				Status:      http.StatusServiceUnavailable,
+				Description: "the access token could not be checked",
+				Err:         err,
+			})
+			return
+		}
+		handler.ServeHTTP(w, r.WithContext(jwtauth.ContextWithClaims(r.Context(), claims)))
+	})
+}
+
+// call posts the token to the introspection endpoint, nil claims for an inactive token.
+func (i *Introspector) call(ctx context.Context, token string) (jwt.MapClaims, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {TokenTypeHintAccessToken}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create introspection request")
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	response, err := i.client.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to call introspection endpoint")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, response.Body)
+		return nil, errors.Errorf("introspection endpoint answered %d", response.StatusCode)
+	}
+	claims := jwt.MapClaims{}
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxFormSize)).Decode(&claims); err != nil {
+		return nil, errors.Wrap(err, "unable to decode introspection response")
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, nil
+	}
+	// Refresh tokens and ID tokens are active too, but do not grant access.
+	if tokenType, found := claims["token_type"]; found && !isAccessTokenType(tokenType) {
+		return nil, nil
+	}
+	delete(claims, "active")
+	return claims, nil
+}
+
+// isAccessTokenType reports whether the token_type of an introspection answer is the one of access tokens.
+func isAccessTokenType(tokenType interface{}) bool {
+	value, _ := tokenType.(string)
+	return strings.EqualFold(value, "Bearer") || value == TokenTypeHintAccessToken
+}
+
+func (i *Introspector) cached(key string) (jwt.MapClaims, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entry, found := i.cache[key]
+	if !found || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.claims, true
+}
+
+// store caches the answer, dropping the expired ones once the cache grows too big.
+func (i *Introspector) store(key string, entry introspection) {
+	if !entry.expiresAt.After(time.Now()) {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.cache) >= maxIntrospectionCacheEntries {
+		now := time.Now()
+		for cachedKey, cached := range i.cache {
+			if now.After(cached.expiresAt) {
+				delete(i.cache, cachedKey)
+			}
+		}
+		if len(i.cache) >= maxIntrospectionCacheEntries {
+			i.cache = map[string]introspection{}
+		}
+	}
+	i.cache[key] = entry
+}
+
+// introspectionKey returns the key answers are cached by, so the cache holds no usable tokens.
+func introspectionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
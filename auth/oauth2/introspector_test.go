@@ -0,0 +1,138 @@
+package oauth2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+)
+
+func newTestIntrospector(t *testing.T, opts ...IntrospectorOption) (*Introspector, *Server, string, *int32) {
+	server, client, secret := newTestServer(t)
+	resource, resourceSecret, err := RegisterClient(server.clients, Client{Name: "orders api"}, false)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	var calls int32
+	handler := server.IntrospectionHandler()
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(endpoint.Close)
+	introspector, err := NewIntrospector(endpoint.URL, resource.ID, resourceSecret, opts...)
+	if err != nil {
+		t.Fatalf("error creating introspector ->> %s", err)
+	}
+	return introspector, server, issueClientToken(t, server, client.ID, secret), &calls
+}
+
+func filterRequest(introspector *Introspector, token string) (*httptest.ResponseRecorder, string) {
+	var scope string
+	handler := introspector.Filter(jwtauth.RequireScopes("orders:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := jwtauth.ClaimsFromContext(r.Context())
+		scope, _ = claims["scope"].(string)
+	})))
+	request := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder, scope
+}
+
+func TestIntrospectorFilter(t *testing.T) {
+	introspector, _, token, calls := newTestIntrospector(t)
+	for n := 0; n < 2; n++ {
+		recorder, scope := filterRequest(introspector, token)
+		if recorder.Code != http.StatusOK || scope != "orders:read" {
+			t.Fatalf("expected the request to pass with the token claims, found %d %q", recorder.Code, scope)
+		}
+	}
+	if *calls != 1 {
+		t.Fatalf("expected the answer to be cached, found %d calls", *calls)
+	}
+
+	for n := 0; n < 2; n++ {
+		if recorder, _ := filterRequest(introspector, "unknown"); recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("expected %d found %d", http.StatusUnauthorized, recorder.Code)
+		}
+	}
+	if *calls != 2 {
+		t.Fatalf("expected the inactive answer to be cached, found %d calls", *calls)
+	}
+	if recorder, _ := filterRequest(introspector, ""); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestIntrospectorCacheExpiry(t *testing.T) {
+	introspector, server, token, calls := newTestIntrospector(t, WithIntrospectionCache(0, 0))
+	if _, err := introspector.Introspect(context.Background(), token); err != nil {
+		t.Fatalf("error introspecting token ->> %s", err)
+	}
+	if err := server.verifier.Revoke(token); err != nil {
+		t.Fatalf("error revoking token ->> %s", err)
+	}
+	if _, err := introspector.Introspect(context.Background(), token); err != ErrTokenInactive {
+		t.Fatalf("expected ErrTokenInactive found %v", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("expected no caching, found %d calls", *calls)
+	}
+}
+
+func TestIntrospectorCacheBoundedByExp(t *testing.T) {
+	introspector, _, token, _ := newTestIntrospector(t, WithIntrospectionCache(time.Hour, time.Hour))
+	if _, err := introspector.Introspect(context.Background(), token); err != nil {
+		t.Fatalf("error introspecting token ->> %s", err)
+	}
+	entry := introspector.cache[introspectionKey(token)]
+	exp := entry.claims["exp"].(float64)
+	if !entry.expiresAt.Equal(time.Unix(int64(exp), 0)) {
+		t.Fatalf("expected the cache entry to expire with the token, found %s", entry.expiresAt)
+	}
+}
+
+func TestIntrospectorUnavailable(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer endpoint.Close()
+	introspector, err := NewIntrospector(endpoint.URL, "orders-api", "secret")
+	if err != nil {
+		t.Fatalf("error creating introspector ->> %s", err)
+	}
+	if recorder, _ := filterRequest(introspector, "token"); recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d found %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+	if _, err := NewIntrospector("ftp://example.com", "orders-api", "secret"); err == nil {
+		t.Fatal("expected an invalid endpoint to be refused")
+	}
+}
+
+func TestIntrospectorRefusesRefreshToken(t *testing.T) {
+	server, _ := newAuthorizeServer(t)
+	resource, resourceSecret, err := RegisterClient(server.clients, Client{Name: "orders api"}, false)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	endpoint := httptest.NewServer(server.IntrospectionHandler())
+	defer endpoint.Close()
+	introspector, err := NewIntrospector(endpoint.URL, resource.ID, resourceSecret)
+	if err != nil {
+		t.Fatalf("error creating introspector ->> %s", err)
+	}
+	response := exchangeCode(t, server, authorizeQuery())
+	if recorder, _ := filterRequest(introspector, response.RefreshToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d for a refresh token found %d", http.StatusUnauthorized, recorder.Code)
+	}
+	if recorder, _ := filterRequest(introspector, response.AccessToken); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d for the access token found %d", http.StatusOK, recorder.Code)
+	}
+}
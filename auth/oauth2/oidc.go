@@ -0,0 +1,179 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// ScopeOpenID is the scope clients request to get an ID token along with the access token.
+const ScopeOpenID = "openid"
+
+// ClaimsProvider returns the claims about the user the userinfo endpoint answers with,
+// such as name and email, limited to what the granted scopes allow. The sub claim
+// is set by the endpoint itself.
+type ClaimsProvider func(ctx context.Context, subject string, scopes []string) (map[string]interface{}, error)
+
+// Endpoints are where the endpoints of the server are mounted, as URLs or as paths
+// relative to the issuer URL. Empty ones are left out of the discovery document.
+type Endpoints struct {
+	Authorization string
+	Token         string
+	JWKS          string
+	UserInfo      string
+	Introspection string
+	Revocation    string
+}
+
+// ProviderMetadata is the discovery document of OpenID Connect Discovery 1.0 section 3.
+type ProviderMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                     string   `json:"token_endpoint,omitempty"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri,omitempty"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                string   `json:"revocation_endpoint,omitempty"`
+	ScopesSupported                   []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported,omitempty"`
+}
+
+// AccessTokenHash returns the at_hash of the access token for an ID token signed with the
+// given signing method, the left half of the hash of the token encoded in base64url.
+func AccessTokenHash(accessToken string, signingMethod string) (string, error) {
+	var h hash.Hash
+	switch {
+	case signingMethod == "EdDSA", strings.HasSuffix(signingMethod, "512"):
+		h = sha512.New()
+	case strings.HasSuffix(signingMethod, "384"):
+		h = sha512.New384()
+	case strings.HasSuffix(signingMethod, "256"):
+		h = sha256.New()
+	default:
+		return "", errors.Errorf("no at_hash for signing method %s", signingMethod)
+	}
+	h.Write([]byte(accessToken))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// issueIDToken signs the ID token of OpenID Connect Core 1.0 section 2 for the user and
+// client of the authorization code, bound to the access token through at_hash.
+func (s *Server) issueIDToken(code AuthorizationCode, accessToken string) (string, *Error) {
+	if s.issuerURL == "" {
+		return "", newError(http.StatusInternalServerError, ErrorServerError, "no issuer URL is configured for OpenID Connect")
+	}
+	signingMethod := s.issuer.Algorithm()
+	atHash, err := AccessTokenHash(accessToken, signingMethod)
+	if err != nil {
+		return "", newError(http.StatusInternalServerError, ErrorServerError, "the ID token could not be issued")
+	}
+	claims := jwt.MapClaims{
+		"iss":       s.issuerURL,
+		"sub":       code.Subject,
+		"aud":       code.ClientID,
+		"azp":       code.ClientID,
+		"auth_time": code.AuthTime.Unix(),
+		"at_hash":   atHash,
+		"token_use": jwtauth.TokenUseID,
+	}
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+	if code.ACR != "" {
+		claims["acr"] = code.ACR
+	}
+	idToken, err := s.issuer.GenerateWithExpiry(claims, s.accessLifetime)
+	if err != nil {
+		return "", newError(http.StatusInternalServerError, ErrorServerError, "the ID token could not be issued")
+	}
+	return idToken, nil
+}
+
+// DiscoveryHandler serves the discovery document, to be mounted at
+// /.well-known/openid-configuration under the issuer URL given with WithIssuerURL.
+func (s *Server) DiscoveryHandler(endpoints Endpoints) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.issuerURL == "" {
+			http.Error(w, "no issuer URL is configured", http.StatusInternalServerError)
+			return
+		}
+		metadata := ProviderMetadata{
+			Issuer:                            s.issuerURL,
+			AuthorizationEndpoint:             s.endpointURL(endpoints.Authorization),
+			TokenEndpoint:                     s.endpointURL(endpoints.Token),
+			UserInfoEndpoint:                  s.endpointURL(endpoints.UserInfo),
+			JWKSURI:                           s.endpointURL(endpoints.JWKS),
+			IntrospectionEndpoint:             s.endpointURL(endpoints.Introspection),
+			RevocationEndpoint:                s.endpointURL(endpoints.Revocation),
+			ScopesSupported:                   []string{ScopeOpenID},
+			ResponseTypesSupported:            []string{"code"},
+			GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeClientCredentials, GrantTypeRefreshToken},
+			SubjectTypesSupported:             []string{"public"},
+			IDTokenSigningAlgValuesSupported:  []string{s.issuer.Algorithm()},
+			TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+			CodeChallengeMethodsSupported:     []string{CodeChallengeMethodS256},
+			ClaimsSupported:                   []string{"iss", "sub", "aud", "azp", "exp", "iat", "auth_time", "nonce", "acr", "at_hash"},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		json.NewEncoder(w).Encode(metadata)
+	})
+}
+
+// endpointURL resolves the path of an endpoint against the issuer URL.
+func (s *Server) endpointURL(endpoint string) string {
+	if endpoint == "" || strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	return strings.TrimSuffix(s.issuerURL, "/") + "/" + strings.TrimPrefix(endpoint, "/")
+}
+
+// UserInfoHandler is the userinfo endpoint of OpenID Connect Core 1.0 section 5.3, to be
+// mounted at a path such as /oauth/userinfo. It requires an access token of the server
+// granted the openid scope, and answers with the claims of WithClaimsProvider.
+func (s *Server) UserInfoHandler() http.Handler {
+	return s.verifier.RequireScopes(ScopeOpenID)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		subject, _ := jwtauth.SubjectFromContext(r.Context())
+		claims, _ := jwtauth.ClaimsFromContext(r.Context())
+		scope, _ := claims["scope"].(string)
+		userInfo := map[string]interface{}{}
+		if s.claims != nil {
+			provided, err := s.claims(r.Context(), subject, strings.Fields(scope))
+			if err != nil {
+				writeError(w, newError(http.StatusInternalServerError, ErrorServerError, "the user claims could not be loaded"))
+				return
+			}
+			for key, value := range provided {
+				userInfo[key] = value
+			}
+		}
+		userInfo["sub"] = subject
+		writeJSON(w, http.StatusOK, userInfo)
+	}))
+}
@@ -0,0 +1,140 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testIssuerURL = "https://auth.example.com"
+
+func exchangeCode(t *testing.T, server *Server, query url.Values) TokenResponse {
+	code := redirectQuery(t, authorize(server, query, "alice")).Get("code")
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"spa"},
+		"code":          {code},
+		"redirect_uri":  {testRedirect},
+		"code_verifier": {testVerifier},
+	}
+	recorder := postToken(server, form, "", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	var response TokenResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("error decoding token response ->> %s", err)
+	}
+	return response
+}
+
+func TestAccessTokenHash(t *testing.T) {
+	// The example of OpenID Connect Core 1.0 appendix A.3.
+	atHash, err := AccessTokenHash("jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y", "RS256")
+	if err != nil || atHash != "77QmUPtjPfzWtF2AnpK9RQ" {
+		t.Fatalf("expected 77QmUPtjPfzWtF2AnpK9RQ found %s ->> %v", atHash, err)
+	}
+	if _, err := AccessTokenHash("token", "none"); err == nil {
+		t.Fatal("expected no at_hash without a hash function")
+	}
+}
+
+func TestIDToken(t *testing.T) {
+	server, _ := newAuthorizeServer(t, WithIssuerURL(testIssuerURL))
+	query := authorizeQuery()
+	query.Set("scope", "openid orders:read")
+	query.Set("nonce", "n-0S6_WzA2Mj")
+	response := exchangeCode(t, server, query)
+	if response.IDToken == "" {
+		t.Fatal("expected an ID token for the openid scope")
+	}
+	claims, err := jwtauth.ParseToken(response.IDToken, tokenKey, jwtauth.WithAlgorithm("HS256"), jwtauth.WithTokenUse(jwtauth.TokenUseID))
+	if err != nil {
+		t.Fatalf("error parsing ID token ->> %s", err)
+	}
+	idClaims := claims.(jwt.MapClaims)
+	atHash, _ := AccessTokenHash(response.AccessToken, "HS256")
+	expected := map[string]interface{}{
+		"iss": testIssuerURL, "sub": "alice", "aud": "spa", "azp": "spa",
+		"nonce": "n-0S6_WzA2Mj", "acr": "pwd", "at_hash": atHash,
+	}
+	for key, value := range expected {
+		if idClaims[key] != value {
+			t.Fatalf("expected %s to be %v found %v", key, value, idClaims[key])
+		}
+	}
+	if _, found := idClaims["auth_time"]; !found {
+		t.Fatalf("expected auth_time in the ID token %v", idClaims)
+	}
+
+	if response := exchangeCode(t, server, authorizeQuery()); response.IDToken != "" {
+		t.Fatal("expected no ID token without the openid scope")
+	}
+}
+
+func TestDiscoveryHandler(t *testing.T) {
+	server, _ := newAuthorizeServer(t, WithIssuerURL(testIssuerURL+"/"))
+	recorder := httptest.NewRecorder()
+	endpoints := Endpoints{Authorization: "/oauth/authorize", Token: "oauth/token", JWKS: "https://keys.example.com/jwks.json"}
+	server.DiscoveryHandler(endpoints).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d", http.StatusOK, recorder.Code)
+	}
+	var metadata ProviderMetadata
+	if err := json.NewDecoder(recorder.Body).Decode(&metadata); err != nil {
+		t.Fatalf("error decoding discovery document ->> %s", err)
+	}
+	if metadata.AuthorizationEndpoint != testIssuerURL+"/oauth/authorize" || metadata.TokenEndpoint != testIssuerURL+"/oauth/token" {
+		t.Fatalf("unexpected endpoints %+v", metadata)
+	}
+	if metadata.JWKSURI != "https://keys.example.com/jwks.json" || metadata.UserInfoEndpoint != "" {
+		t.Fatalf("unexpected endpoints %+v", metadata)
+	}
+	if len(metadata.IDTokenSigningAlgValuesSupported) != 1 || metadata.IDTokenSigningAlgValuesSupported[0] != "HS256" {
+		t.Fatalf("unexpected signing methods %v", metadata.IDTokenSigningAlgValuesSupported)
+	}
+}
+
+func TestUserInfoHandler(t *testing.T) {
+	provider := func(ctx context.Context, subject string, scopes []string) (map[string]interface{}, error) {
+		return map[string]interface{}{"sub": "someone else", "name": "Alice", "scopes": len(scopes)}, nil
+	}
+	server, _ := newAuthorizeServer(t, WithIssuerURL(testIssuerURL), WithClaimsProvider(provider))
+	query := authorizeQuery()
+	query.Set("scope", "openid orders:read")
+	response := exchangeCode(t, server, query)
+
+	userInfo := func(token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		server.UserInfoHandler().ServeHTTP(recorder, request)
+		return recorder
+	}
+	recorder := userInfo(response.AccessToken)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	claims := map[string]interface{}{}
+	if err := json.NewDecoder(recorder.Body).Decode(&claims); err != nil {
+		t.Fatalf("error decoding userinfo ->> %s", err)
+	}
+	if claims["sub"] != "alice" || claims["name"] != "Alice" || claims["scopes"] != float64(2) {
+		t.Fatalf("unexpected userinfo %v", claims)
+	}
+
+	// Access tokens without the openid scope are not enough.
+	if recorder := userInfo(exchangeCode(t, server, authorizeQuery()).AccessToken); recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected %d found %d", http.StatusForbidden, recorder.Code)
+	}
+	// ID tokens are no access tokens.
+	if recorder := userInfo(response.IDToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d for an ID token found %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package oauth2
+
+import (
+	"time"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/bellomd/miniauth/auth/opaquetoken"
+	"github.com/pkg/errors"
+)
+
+// defaultAccessTokenLifetime is how long access tokens live unless WithAccessTokenLifetime says otherwise.
+const defaultAccessTokenLifetime = 1 * time.Hour
+
+// defaultRefreshTokenLifetime is how long refresh tokens live unless WithRefreshStore says otherwise.
+const defaultRefreshTokenLifetime = 30 * 24 * time.Hour
+
+// maxFormSize bounds the size of the requests the endpoints read.
+const maxFormSize = 64 << 10
+
+// Server is an authorization server issuing access tokens with a jwtauth.Issuer
+// to the clients of its client store.
+type Server struct {
+	issuer           *jwtauth.Issuer
+	verifier         *jwtauth.Verifier
+	opaque           *opaquetoken.Manager
+	clients          ClientStore
+	codes            CodeStore
+	refreshStore     jwtauth.RefreshStore
+	refreshLifetime  time.Duration
+	refresh          *jwtauth.RefreshManager
+	authenticateUser UserAuthenticator
+	consent          ConsentFunc
+	issuerURL        string
+	claims           ClaimsProvider
+	accessLifetime   time.Duration
+	codeLifetime     time.Duration
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAccessTokenLifetime sets how long issued access tokens live, one hour by default.
+func WithAccessTokenLifetime(lifetime time.Duration) Option {
+	return func(s *Server) {
+		s.accessLifetime = lifetime
+	}
+}
+
+// WithVerifier sets the verifier the introspection and revocation endpoints check access
+// tokens with, by default the one of the issuer. Revoked tokens go to its revocation store.
+func WithVerifier(verifier *jwtauth.Verifier) Option {
+	return func(s *Server) {
+		s.verifier = verifier
+	}
+}
+
+// WithOpaqueTokens has the introspection and revocation endpoints accept the opaque tokens
+// of the given manager too, the client_id of their metadata tells which client they belong to.
+func WithOpaqueTokens(manager *opaquetoken.Manager) Option {
+	return func(s *Server) {
+		s.opaque = manager
+	}
+}
+
+// WithIssuerURL sets the issuer identifier of the server for OpenID Connect, an https URL
+// such as https://auth.example.com that ID tokens carry as iss and the discovery document
+// is served under. It is required to issue ID tokens.
+func WithIssuerURL(issuerURL string) Option {
+	return func(s *Server) {
+		s.issuerURL = issuerURL
+	}
+}
+
+// WithClaimsProvider sets where the userinfo endpoint gets the claims about users from.
+func WithClaimsProvider(provider ClaimsProvider) Option {
+	return func(s *Server) {
+		s.claims = provider
+	}
+}
+
+// WithUserAuthenticator sets how the authorize endpoint finds out who the user is,
+// it is required for the authorization_code grant.
+func WithUserAuthenticator(authenticator UserAuthenticator) Option {
+	return func(s *Server) {
+		s.authenticateUser = authenticator
+	}
+}
+
+// WithConsent sets how the authorize endpoint asks the user for consent, by default
+// every requested scope is granted, which only suits first party clients.
+func WithConsent(consent ConsentFunc) Option {
+	return func(s *Server) {
+		s.consent = consent
+	}
+}
+
+// WithCodeStore sets where authorization codes are kept, in memory by default.
+func WithCodeStore(codes CodeStore) Option {
+	return func(s *Server) {
+		s.codes = codes
+	}
+}
+
+// WithCodeLifetime sets how long authorization codes can be exchanged, one minute by default.
+func WithCodeLifetime(lifetime time.Duration) Option {
+	return func(s *Server) {
+		s.codeLifetime = lifetime
+	}
+}
+
+// WithRefreshStore sets where refresh tokens are kept and how long they live, in memory
+// for thirty days by default. Refresh tokens are rotated on every use, see jwtauth.RefreshManager.
+func WithRefreshStore(store jwtauth.RefreshStore, lifetime time.Duration) Option {
+	return func(s *Server) {
+		s.refreshStore, s.refreshLifetime = store, lifetime
+	}
+}
+
+// NewServer creates an authorization server signing access tokens with the given issuer,
+// jwtauth.DefaultIssuer() for the one configured through the os environment.
+func NewServer(issuer *jwtauth.Issuer, clients ClientStore, opts ...Option) (*Server, error) {
+	if issuer == nil {
+		return nil, errors.New("invalid issuer")
+	}
+	if clients == nil {
+		return nil, errors.New("invalid client store")
+	}
+	s := &Server{
+		issuer:          issuer,
+		verifier:        issuer.Verifier(),
+		clients:         clients,
+		codes:           NewMemoryCodeStore(),
+		refreshStore:    jwtauth.NewMemoryRefreshStore(),
+		refreshLifetime: defaultRefreshTokenLifetime,
+		accessLifetime:  defaultAccessTokenLifetime,
+		codeLifetime:    defaultCodeLifetime,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.refresh = jwtauth.NewRefreshManager(issuer, s.refreshStore, s.refreshLifetime, jwtauth.WithAccessLifetime(s.accessLifetime))
+	return s, nil
+}
@@ -0,0 +1,263 @@
+package oauth2
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// TokenResponse is the successful answer of the token endpoint, RFC 6749 section 5.1.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// IDToken is the OpenID Connect ID token, issued when the openid scope was granted.
+	IDToken string `json:"id_token,omitempty"`
+}
+
+// TokenHandler is the token endpoint, to be mounted at a path such as /oauth/token.
+// It supports the client_credentials grant of RFC 6749 section 4.4, the authorization_code
+// grant of section 4.1 with PKCE and the refresh_token grant of section 6. Confidential
+// clients authenticate through HTTP Basic or the client_id and client_secret form
+// parameters, public clients send their client_id alone.
+func (s *Server) TokenHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, newError(http.StatusMethodNotAllowed, ErrorInvalidRequest, "the token endpoint only accepts POST"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if err := r.ParseForm(); err != nil {
+			writeError(w, newError(http.StatusBadRequest, ErrorInvalidRequest, "the request body could not be read"))
+			return
+		}
+		client, oauthErr := s.authenticateClient(r)
+		if oauthErr != nil {
+			writeError(w, oauthErr)
+			return
+		}
+
+		grantType := r.PostForm.Get("grant_type")
+		if grantType == "" {
+			writeError(w, newError(http.StatusBadRequest, ErrorInvalidRequest, "missing grant_type"))
+			return
+		}
+		grant, supported := s.grant(grantType)
+		if !supported {
+			writeError(w, newError(http.StatusBadRequest, ErrorUnsupportedGrantType, "the grant type is not supported"))
+			return
+		}
+		if !client.Allows(grantType) {
+			writeError(w, newError(http.StatusBadRequest, ErrorUnauthorizedClient, "the client may not use this grant type"))
+			return
+		}
+		response, oauthErr := grant(r, client)
+		if oauthErr != nil {
+			writeError(w, oauthErr)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// grantFunc answers a token request of one grant type for the authenticated client.
+type grantFunc func(r *http.Request, client Client) (*TokenResponse, *Error)
+
+// grant returns how the token endpoint answers the given grant type.
+func (s *Server) grant(grantType string) (grantFunc, bool) {
+	switch grantType {
+	case GrantTypeClientCredentials:
+		return s.clientCredentials, true
+	case GrantTypeAuthorizationCode:
+		return s.authorizationCode, true
+	case GrantTypeRefreshToken:
+		return s.refreshToken, true
+	}
+	return nil, false
+}
+
+// clientCredentials issues an access token to a confidential client on its own behalf.
+func (s *Server) clientCredentials(r *http.Request, client Client) (*TokenResponse, *Error) {
+	if client.Public() {
+		return nil, newError(http.StatusBadRequest, ErrorUnauthorizedClient, "public clients cannot use the client_credentials grant")
+	}
+	scopes, oauthErr := grantScopes(client, r.PostForm.Get("scope"))
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+	return s.issueAccessToken(jwt.MapClaims{"sub": client.ID}, client, scopes)
+}
+
+// authorizationCode exchanges a code of the authorize endpoint for tokens on behalf of the user.
+func (s *Server) authorizationCode(r *http.Request, client Client) (*TokenResponse, *Error) {
+	code, err := s.codes.TakeCode(hashCode(r.PostForm.Get("code")))
+	if err == ErrCodeNotFound {
+		return nil, invalidGrantError("the authorization code is invalid or was used already")
+	}
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, ErrorServerError, "the authorization code could not be loaded")
+	}
+	if code.ClientID != client.ID || time.Now().After(code.ExpiresAt) {
+		return nil, invalidGrantError("the authorization code is invalid or was used already")
+	}
+	if r.PostForm.Get("redirect_uri") != code.RedirectURI {
+		return nil, invalidGrantError("the redirect_uri does not match the authorization request")
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, r.PostForm.Get("code_verifier")) {
+		return nil, invalidGrantError("the code_verifier does not match the code_challenge")
+	}
+	claims := jwt.MapClaims{"sub": code.Subject, "auth_time": code.AuthTime.Unix()}
+	var response *TokenResponse
+	var oauthErr *Error
+	if client.Allows(GrantTypeRefreshToken) {
+		response, oauthErr = s.issueTokenPair(claims, client, code.Scopes)
+	} else {
+		response, oauthErr = s.issueAccessToken(claims, client, code.Scopes)
+	}
+	if oauthErr != nil || !contains(code.Scopes, ScopeOpenID) {
+		return response, oauthErr
+	}
+	if response.IDToken, oauthErr = s.issueIDToken(code, response.AccessToken); oauthErr != nil {
+		return nil, oauthErr
+	}
+	return response, nil
+}
+
+// refreshToken rotates a refresh token for a new token pair with the same scopes.
+func (s *Server) refreshToken(r *http.Request, client Client) (*TokenResponse, *Error) {
+	refreshToken := r.PostForm.Get("refresh_token")
+	record, err := s.refresh.Lookup(refreshToken)
+	if err == nil && record.Claims["client_id"] != client.ID {
+		// Another client holding the refresh token means it leaked, none of its family can be trusted.
+		if err := s.refresh.Revoke(refreshToken); err != nil {
+			return nil, newError(http.StatusInternalServerError, ErrorServerError, "the refresh token could not be revoked")
+		}
+		return nil, invalidGrantError("the refresh token is invalid")
+	}
+	if err != nil && !errors.Is(err, jwtauth.ErrRefreshTokenInvalid) {
+		return nil, newError(http.StatusInternalServerError, ErrorServerError, "the refresh token could not be used")
+	}
+
+	pair, err := s.refresh.Refresh(refreshToken)
+	if errors.Is(err, jwtauth.ErrRefreshTokenInvalid) || errors.Is(err, jwtauth.ErrRefreshTokenReused) {
+		return nil, invalidGrantError("the refresh token is invalid")
+	}
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, ErrorServerError, "the refresh token could not be used")
+	}
+	return tokenResponse(pair, s.accessLifetime), nil
+}
+
+// issueTokenPair signs an access token with the given claims for the client, along with a refresh token.
+func (s *Server) issueTokenPair(claims jwt.MapClaims, client Client, scopes []string) (*TokenResponse, *Error) {
+	claims["client_id"] = client.ID
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	pair, err := s.refresh.Issue(claims)
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, ErrorServerError, "the tokens could not be issued")
+	}
+	return tokenResponse(pair, s.accessLifetime), nil
+}
+
+func tokenResponse(pair *jwtauth.TokenPair, accessLifetime time.Duration) *TokenResponse {
+	scope, _ := pair.Claims["scope"].(string)
+	return &TokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessLifetime.Seconds()),
+		RefreshToken: pair.RefreshToken,
+		Scope:        scope,
+	}
+}
+
+func invalidGrantError(description string) *Error {
+	return newError(http.StatusBadRequest, ErrorInvalidGrant, description)
+}
+
+// issueAccessToken signs an access token with the given claims for the client.
+func (s *Server) issueAccessToken(claims jwt.MapClaims, client Client, scopes []string) (*TokenResponse, *Error) {
+	claims["client_id"] = client.ID
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	accessToken, err := s.issuer.GenerateWithExpiry(claims, s.accessLifetime)
+	if err != nil {
+		return nil, newError(http.StatusInternalServerError, ErrorServerError, "the access token could not be issued")
+	}
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.accessLifetime.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// authenticateClient identifies the client through HTTP Basic, or the client_id and
+// client_secret form parameters, public clients through client_id alone.
+func (s *Server) authenticateClient(r *http.Request) (Client, *Error) {
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 has the credentials form encoded before they are put in the header.
+		var idErr, secretErr error
+		clientID, idErr = url.QueryUnescape(clientID)
+		secret, secretErr = url.QueryUnescape(secret)
+		if idErr != nil || secretErr != nil {
+			return Client{}, invalidClientError()
+		}
+		formID := r.PostForm.Get("client_id")
+		if r.PostForm.Get("client_secret") != "" || (formID != "" && formID != clientID) {
+			return Client{}, newError(http.StatusBadRequest, ErrorInvalidRequest, "use a single client authentication method")
+		}
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID == "" {
+		return Client{}, invalidClientError()
+	}
+	client, err := s.clients.Client(clientID)
+	if err == ErrClientNotFound {
+		return Client{}, invalidClientError()
+	}
+	if err != nil {
+		return Client{}, newError(http.StatusInternalServerError, ErrorServerError, "the client could not be loaded")
+	}
+	if client.Public() {
+		if secret != "" {
+			return Client{}, invalidClientError()
+		}
+		return client, nil
+	}
+	if !VerifySecret(client.SecretHash, secret) {
+		return Client{}, invalidClientError()
+	}
+	return client, nil
+}
+
+func invalidClientError() *Error {
+	return newError(http.StatusUnauthorized, ErrorInvalidClient, "client authentication failed")
+}
+
+// grantScopes returns the requested scopes, all the scopes of the client when none are
+// requested, and invalid_scope when the client may not have one of them.
+func grantScopes(client Client, requested string) ([]string, *Error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return client.Scopes, nil
+	}
+	for _, scope := range scopes {
+		if !contains(client.Scopes, scope) {
+			return nil, newError(http.StatusBadRequest, ErrorInvalidScope, "the client may not request scope "+scope)
+		}
+	}
+	return scopes, nil
+}
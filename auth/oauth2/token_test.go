@@ -0,0 +1,115 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var tokenKey = []byte("oauth2-test-key")
+
+func newTestServer(t *testing.T) (*Server, Client, string) {
+	issuer, err := jwtauth.NewIssuer(jwtauth.WithKey(tokenKey), jwtauth.WithAlgorithm("HS256"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	store := NewMemoryClientStore()
+	client, secret, err := RegisterClient(store, Client{
+		Scopes:     []string{"orders:read", "orders:write"},
+		GrantTypes: []string{GrantTypeClientCredentials},
+	}, false)
+	if err != nil {
+		t.Fatalf("error registering client ->> %s", err)
+	}
+	server, err := NewServer(issuer, store)
+	if err != nil {
+		t.Fatalf("error creating server ->> %s", err)
+	}
+	return server, client, secret
+}
+
+func postToken(server *Server, form url.Values, clientID, secret string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		request.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+	}
+	recorder := httptest.NewRecorder()
+	server.TokenHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func decodeError(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	var oauthErr Error
+	if err := json.NewDecoder(recorder.Body).Decode(&oauthErr); err != nil {
+		t.Fatalf("error decoding error response ->> %s", err)
+	}
+	return oauthErr.Code
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	server, client, secret := newTestServer(t)
+	recorder := postToken(server, url.Values{"grant_type": {"client_credentials"}, "scope": {"orders:read"}}, client.ID, secret)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+	if recorder.Header().Get("Cache-Control") != "no-store" {
+		t.Fatal("expected the token response not to be cached")
+	}
+	var response TokenResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("error decoding token response ->> %s", err)
+	}
+	claims, err := jwtauth.ParseToken(response.AccessToken, tokenKey, jwtauth.WithAlgorithm("HS256"))
+	if err != nil {
+		t.Fatalf("error parsing access token ->> %s", err)
+	}
+	if mapClaims := claims.(jwt.MapClaims); mapClaims["sub"] != client.ID || mapClaims["scope"] != "orders:read" {
+		t.Fatalf("unexpected access token claims %v", mapClaims)
+	}
+	if response.TokenType != "Bearer" || response.ExpiresIn != 3600 {
+		t.Fatalf("unexpected token response %+v", response)
+	}
+
+	// The client credentials in the form body work as well.
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {client.ID}, "client_secret": {secret}}
+	if recorder := postToken(server, form, "", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("expected %d found %d: %s", http.StatusOK, recorder.Code, recorder.Body)
+	}
+}
+
+func TestTokenEndpointErrors(t *testing.T) {
+	server, client, secret := newTestServer(t)
+	tests := []struct {
+		name     string
+		form     url.Values
+		clientID string
+		secret   string
+		status   int
+		code     string
+	}{
+		{"wrong secret", url.Values{"grant_type": {"client_credentials"}}, client.ID, "wrong", http.StatusUnauthorized, ErrorInvalidClient},
+		{"unknown client", url.Values{"grant_type": {"client_credentials"}}, "unknown", secret, http.StatusUnauthorized, ErrorInvalidClient},
+		{"no client", url.Values{"grant_type": {"client_credentials"}}, "", "", http.StatusUnauthorized, ErrorInvalidClient},
+		{"no grant type", url.Values{}, client.ID, secret, http.StatusBadRequest, ErrorInvalidRequest},
+		{"unsupported grant", url.Values{"grant_type": {"password"}}, client.ID, secret, http.StatusBadRequest, ErrorUnsupportedGrantType},
+		{"unauthorized grant", url.Values{"grant_type": {"authorization_code"}, "code": {"code"}}, client.ID, secret, http.StatusBadRequest, ErrorUnauthorizedClient},
+		{"scope", url.Values{"grant_type": {"client_credentials"}, "scope": {"admin"}}, client.ID, secret, http.StatusBadRequest, ErrorInvalidScope},
+		{"two methods", url.Values{"grant_type": {"client_credentials"}, "client_secret": {secret}}, client.ID, secret, http.StatusBadRequest, ErrorInvalidRequest},
+	}
+	for _, test := range tests {
+		recorder := postToken(server, test.form, test.clientID, test.secret)
+		if recorder.Code != test.status {
+			t.Fatalf("%s: expected %d found %d", test.name, test.status, recorder.Code)
+		}
+		if code := decodeError(t, recorder); code != test.code {
+			t.Fatalf("%s: expected %s found %s", test.name, test.code, code)
+		}
+	}
+}
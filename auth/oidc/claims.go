@@ -0,0 +1,70 @@
+package oidc
+
+import (
+	"encoding/json"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// Audience is the aud claim, which ID tokens carry as a single string or an array of them.
+type Audience []string
+
+// UnmarshalJSON accepts a single string as well as an array.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.New("aud is neither a string nor an array of strings")
+	}
+	*a = multiple
+	return nil
+}
+
+// Contains reports whether the given audience is one of them.
+func (a Audience) Contains(audience string) bool {
+	for _, value := range a {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// IDTokenClaims are the claims of an ID token, OpenID Connect Core 1.0 sections 2 and 5.1.
+type IDTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        Audience `json:"aud"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	AuthTime        int64    `json:"auth_time,omitempty"`
+	Nonce           string   `json:"nonce,omitempty"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	ACR             string   `json:"acr,omitempty"`
+	AMR             []string `json:"amr,omitempty"`
+	AccessTokenHash string   `json:"at_hash,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Email           string   `json:"email,omitempty"`
+	EmailVerified   bool     `json:"email_verified,omitempty"`
+	// Claims holds every claim of the token, including the ones without a field above.
+	Claims jwt.MapClaims `json:"-"`
+}
+
+// newIDTokenClaims fills the typed claims from the verified map claims.
+func newIDTokenClaims(claims jwt.MapClaims) (*IDTokenClaims, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid claims")
+	}
+	idClaims := &IDTokenClaims{}
+	if err := json.Unmarshal(data, idClaims); err != nil {
+		return nil, errors.Wrap(err, "invalid claims")
+	}
+	idClaims.Claims = claims
+	return idClaims, nil
+}
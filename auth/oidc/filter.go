@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+	"github.com/bellomd/miniauth/auth/jwtauth"
+)
+
+type contextKey int
+
+const idTokenContextKey contextKey = iota
+
+// ContextWithIDToken returns a copy of the context carrying the given ID token claims.
+func ContextWithIDToken(ctx context.Context, claims *IDTokenClaims) context.Context {
+	return context.WithValue(ctx, idTokenContextKey, claims)
+}
+
+// IDTokenFromContext returns the ID token claims Filter stored in the request context.
+func IDTokenFromContext(ctx context.Context) (*IDTokenClaims, bool) {
+	claims, ok := ctx.Value(idTokenContextKey).(*IDTokenClaims)
+	return claims, ok
+}
+
+// Filter check if the request has a bearer ID token of the provider, see Verify. The claims
+// are passed on in the request context for IDTokenFromContext and jwtauth.ClaimsFromContext.
+func (v *Verifier) Filter(handler http.Handler) http.Handler {
+	return Filter(v)(handler)
+}
+
+// Filter returns a middleware accepting bearer ID tokens of several providers at once. Each
+// token is verified by the verifier of the provider its iss names, and only by that one, so
+// a provider can never get its tokens accepted with the keys or the client of another.
+// Refused requests are answered like jwtauth filters do.
+func Filter(verifiers ...*Verifier) func(http.Handler) http.Handler {
+	byIssuer := map[string]*Verifier{}
+	for _, verifier := range verifiers {
+		byIssuer[verifier.Issuer()] = verifier
+	}
+	extractor := jwtauth.BearerExtractor(authenv.AuthorizationHeader)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := extractor.Extract(r)
+			if err != nil {
+				jwtauth.BearerErrorHandler(w, r, jwtauth.NewAuthError(err))
+				return
+			}
+			verifier, found := byIssuer[unverifiedIssuer(token)]
+			if !found {
+				jwtauth.BearerErrorHandler(w, r, jwtauth.NewAuthError(ErrUnknownIssuer))
+				return
+			}
+			claims, err := verifier.Verify(token, "")
+			if err != nil {
+				jwtauth.BearerErrorHandler(w, r, jwtauth.NewAuthError(err))
+				return
+			}
+			ctx := ContextWithIDToken(r.Context(), claims)
+			ctx = jwtauth.ContextWithClaims(ctx, claims.Claims)
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
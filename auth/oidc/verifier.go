@@ -0,0 +1,274 @@
+package oidc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/bellomd/miniauth/auth/oauth2"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// discoveryPath is where providers serve their discovery document under their issuer URL.
+	discoveryPath = "/.well-known/openid-configuration"
+	// defaultJWKSRefreshInterval is how long the key set of a provider is cached.
+	defaultJWKSRefreshInterval = 15 * time.Minute
+	// discoveryRetryInterval is how long a failed discovery is remembered before trying again.
+	discoveryRetryInterval = 10 * time.Second
+	// maxDiscoverySize bounds the size of the discovery documents read.
+	maxDiscoverySize = 1 << 20
+	// revocationTTL is how long the revoked subjects of a provider are remembered.
+	revocationTTL = 24 * time.Hour
+)
+
+// Errors for ID tokens that fail the OpenID Connect checks on top of the jwtauth ones,
+// such as jwtauth.ErrIssuerMismatch, ErrAudienceMismatch or ErrTokenExpired.
+var (
+	ErrNonceMismatch           = errors.New("nonce does not match")
+	ErrAuthorizedPartyMismatch = errors.New("azp does not match")
+	ErrUnknownIssuer           = errors.New("issuer is not trusted")
+)
+
+// Verifier validates the ID tokens a client gets from an OpenID Connect provider, with the
+// keys of the key set named in the discovery document of the provider.
+type Verifier struct {
+	issuer      string
+	clientID    string
+	discovery   string
+	jwks        string
+	algorithms  []string
+	leeway      time.Duration
+	maxAge      time.Duration
+	refresh     time.Duration
+	client      *http.Client
+	revocation  jwtauth.RevocationStore
+	jwtVerifier *jwtauth.Verifier
+
+	mu          sync.Mutex
+	metadata    *oauth2.ProviderMetadata
+	loadErr     error
+	attemptedAt time.Time
+}
+
+// Option configures a Verifier.
+type Option func(*Verifier)
+
+// WithDiscovery sets where the discovery document is loaded from, an http(s) URL or a file
+// path, by default /.well-known/openid-configuration under the issuer URL.
+func WithDiscovery(source string) Option {
+	return func(v *Verifier) {
+		v.discovery = source
+	}
+}
+
+// WithJWKS sets where the key set is loaded from, an http(s) URL or a file path, in place
+// of the jwks_uri of the discovery document.
+func WithJWKS(source string, refreshInterval time.Duration) Option {
+	return func(v *Verifier) {
+		v.jwks, v.refresh = source, refreshInterval
+	}
+}
+
+// WithAlgorithms sets the signing methods accepted, by default the asymmetric ones the
+// discovery document lists, or RS256 when it lists none.
+func WithAlgorithms(signingMethods ...string) Option {
+	return func(v *Verifier) {
+		v.algorithms = signingMethods
+	}
+}
+
+// WithLeeway tolerates the given clock skew when checking exp and iat.
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithMaxAge rejects ID tokens issued longer ago than the given age.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(v *Verifier) {
+		v.maxAge = maxAge
+	}
+}
+
+// WithHTTPClient sets the client the discovery document is loaded with.
+func WithHTTPClient(client *http.Client) Option {
+	return func(v *Verifier) {
+		v.client = client
+	}
+}
+
+// WithRevocationStore sets where revoked ID tokens of the provider are kept. By default every
+// verifier has an in memory store of its own, the default jwtauth store is never consulted,
+// so that local revocations cannot hit the users of a provider.
+func WithRevocationStore(store jwtauth.RevocationStore) Option {
+	return func(v *Verifier) {
+		v.revocation = store
+	}
+}
+
+// NewVerifier creates a verifier for the ID tokens the provider with the given issuer URL
+// issues to the given client. The discovery document and key set are loaded on first use.
+func NewVerifier(issuer string, clientID string, opts ...Option) (*Verifier, error) {
+	if issuer == "" {
+		return nil, errors.New("invalid issuer")
+	}
+	if clientID == "" {
+		return nil, errors.New("invalid client id")
+	}
+	v := &Verifier{
+		issuer:    issuer,
+		clientID:  clientID,
+		discovery: strings.TrimSuffix(issuer, "/") + discoveryPath,
+		refresh:   defaultJWKSRefreshInterval,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	if v.revocation == nil {
+		v.revocation = jwtauth.NewMemoryRevocationStore(revocationTTL)
+	}
+	return v, nil
+}
+
+// Issuer returns the issuer URL of the provider.
+func (v *Verifier) Issuer() string {
+	return v.issuer
+}
+
+// Metadata returns the discovery document of the provider, loading it when needed.
+func (v *Verifier) Metadata() (*oauth2.ProviderMetadata, error) {
+	if _, err := v.JWTVerifier(); err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.metadata, nil
+}
+
+// Verify validates the ID token and returns its claims. The signature, iss, aud, exp and
+// iat are checked, azp has to be the client when present or when there are several
+// audiences, and nonce has to be the given one unless it is empty.
+func (v *Verifier) Verify(idToken string, nonce string) (*IDTokenClaims, error) {
+	jwtVerifier, err := v.JWTVerifier()
+	if err != nil {
+		return nil, err
+	}
+	claims, err := jwtVerifier.Parse(idToken)
+	if err != nil {
+		return nil, err
+	}
+	idClaims, err := newIDTokenClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	if (len(idClaims.Audience) > 1 || idClaims.AuthorizedParty != "") && idClaims.AuthorizedParty != v.clientID {
+		return nil, ErrAuthorizedPartyMismatch
+	}
+	if nonce != "" && idClaims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	return idClaims, nil
+}
+
+// JWTVerifier returns the jwtauth verifier behind Verify, which checks everything but azp and
+// nonce. It can be given to jwtauth.SetDefault, for DoFilter to accept the ID tokens of the
+// provider as bearer tokens, or to jwtauth.WithTenants next to the verifiers of other providers.
+func (v *Verifier) JWTVerifier() (*jwtauth.Verifier, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.jwtVerifier != nil {
+		return v.jwtVerifier, nil
+	}
+	if v.loadErr != nil && time.Since(v.attemptedAt) < discoveryRetryInterval {
+		return nil, v.loadErr
+	}
+	v.attemptedAt = time.Now()
+	v.jwtVerifier, v.loadErr = v.load()
+	return v.jwtVerifier, v.loadErr
+}
+
+// load reads the discovery document and creates the verifier for the key set it names.
+func (v *Verifier) load() (*jwtauth.Verifier, error) {
+	data, err := v.read(v.discovery)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load discovery document from %s", v.discovery)
+	}
+	metadata := &oauth2.ProviderMetadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode discovery document from %s", v.discovery)
+	}
+	// OpenID Connect Discovery 1.0 section 4.3, the document must be the one of the issuer.
+	if metadata.Issuer != v.issuer {
+		return nil, errors.Errorf("discovery document is for issuer %q, not %q", metadata.Issuer, v.issuer)
+	}
+	jwks := v.jwks
+	if jwks == "" {
+		jwks = metadata.JWKSURI
+	}
+	if jwks == "" {
+		return nil, errors.New("discovery document has no jwks_uri")
+	}
+	algorithms := v.algorithms
+	if len(algorithms) == 0 {
+		for _, signingMethod := range metadata.IDTokenSigningAlgValuesSupported {
+			if jwtauth.IsAsymmetric(signingMethod) {
+				algorithms = append(algorithms, signingMethod)
+			}
+		}
+	}
+	if len(algorithms) == 0 {
+		algorithms = []string{"RS256"}
+	}
+	jwtVerifier, err := jwtauth.NewVerifier(
+		jwtauth.WithKeyResolver(jwtauth.NewJWKSResolver(jwks, v.refresh)),
+		jwtauth.WithAllowedAlgorithms(algorithms...),
+		jwtauth.WithIssuers(v.issuer),
+		jwtauth.WithAudiences(v.clientID),
+		jwtauth.WithRequiredClaims("sub", "exp", "iat"),
+		jwtauth.WithLeeway(v.leeway),
+		jwtauth.WithMaxAge(v.maxAge),
+		jwtauth.WithRevocationStore(v.revocation),
+		jwtauth.WithTokenUse(jwtauth.TokenUseID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	v.metadata = metadata
+	return jwtVerifier, nil
+}
+
+// read loads the given http(s) URL or file.
+func (v *Verifier) read(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(source, "file://"))
+	}
+	response, err := v.client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxDiscoverySize))
+}
+
+// unverifiedIssuer returns the iss claim of the token without verifying anything,
+// only to pick the verifier that will.
+func unverifiedIssuer(token string) string {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return ""
+	}
+	issuer, _ := claims["iss"].(string)
+	return issuer
+}
@@ -0,0 +1,217 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/bellomd/miniauth/auth/oauth2"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testProvider is an OpenID Connect provider serving its discovery document and key set.
+type testProvider struct {
+	server *httptest.Server
+	issuer *jwtauth.Issuer
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key ->> %s", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("error encoding key ->> %s", err)
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	issuer, err := jwtauth.NewIssuer(jwtauth.WithKey(privatePEM), jwtauth.WithAlgorithm("ES256"), jwtauth.WithKeyID("k1"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	provider := &testProvider{issuer: issuer}
+	mux := http.NewServeMux()
+	mux.Handle("/jwks.json", jwtauth.JWKSHandler(issuer))
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oauth2.ProviderMetadata{
+			Issuer:                           provider.server.URL,
+			JWKSURI:                          provider.server.URL + "/jwks.json",
+			IDTokenSigningAlgValuesSupported: []string{"ES256", "HS256"},
+		})
+	})
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+func (p *testProvider) idToken(t *testing.T, overrides jwt.MapClaims) string {
+	claims := jwt.MapClaims{"iss": p.server.URL, "sub": "alice", "aud": "app", "nonce": "n-1", "name": "Alice"}
+	for key, value := range overrides {
+		if value == nil {
+			delete(claims, key)
+			continue
+		}
+		claims[key] = value
+	}
+	token, err := p.issuer.Generate(claims)
+	if err != nil {
+		t.Fatalf("error generating ID token ->> %s", err)
+	}
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier, err := NewVerifier(provider.server.URL, "app")
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	claims, err := verifier.Verify(provider.idToken(t, nil), "n-1")
+	if err != nil {
+		t.Fatalf("error verifying ID token ->> %s", err)
+	}
+	if claims.Subject != "alice" || claims.Name != "Alice" || !claims.Audience.Contains("app") || claims.Claims["nonce"] != "n-1" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+	if _, err := verifier.Verify(provider.idToken(t, jwt.MapClaims{"aud": []string{"app", "other"}, "azp": "app"}), ""); err != nil {
+		t.Fatalf("error verifying ID token with several audiences ->> %s", err)
+	}
+
+	hmacIssuer, err := jwtauth.NewIssuer(jwtauth.WithKey([]byte("secret")), jwtauth.WithAlgorithm("HS256"))
+	if err != nil {
+		t.Fatalf("error creating issuer ->> %s", err)
+	}
+	hmacToken, err := hmacIssuer.Generate(jwt.MapClaims{"iss": provider.server.URL, "sub": "alice", "aud": "app"})
+	if err != nil {
+		t.Fatalf("error generating ID token ->> %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		err   error
+	}{
+		{"nonce", provider.idToken(t, nil), "n-2", ErrNonceMismatch},
+		{"issuer", provider.idToken(t, jwt.MapClaims{"iss": "https://other.example.com"}), "", jwtauth.ErrIssuerMismatch},
+		{"audience", provider.idToken(t, jwt.MapClaims{"aud": "other"}), "", jwtauth.ErrAudienceMismatch},
+		{"no azp", provider.idToken(t, jwt.MapClaims{"aud": []string{"app", "other"}}), "", ErrAuthorizedPartyMismatch},
+		{"azp", provider.idToken(t, jwt.MapClaims{"azp": "other"}), "", ErrAuthorizedPartyMismatch},
+		{"expired", provider.idToken(t, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), "", jwtauth.ErrTokenExpired},
+		{"no sub", provider.idToken(t, jwt.MapClaims{"sub": nil}), "", jwtauth.ErrMissingClaim},
+		{"algorithm", hmacToken, "", jwtauth.ErrAlgorithmNotAllowed},
+	}
+	for _, test := range tests {
+		if _, err := verifier.Verify(test.token, test.nonce); !errors.Is(err, test.err) {
+			t.Fatalf("%s: expected %v found %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestVerifyRevocation(t *testing.T) {
+	previous := jwtauth.DefaultRevocationStore()
+	jwtauth.SetRevocationStore(jwtauth.NewMemoryRevocationStore(time.Hour))
+	defer jwtauth.SetRevocationStore(previous)
+
+	provider := newTestProvider(t)
+	verifier, err := NewVerifier(provider.server.URL, "app")
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	idToken := provider.idToken(t, jwt.MapClaims{"iat": time.Now().Add(-time.Minute).Unix()})
+	if err := jwtauth.RevokeSubject(provider.server.URL, "alice", time.Now()); err != nil {
+		t.Fatalf("error revoking subject ->> %s", err)
+	}
+	if _, err := verifier.Verify(idToken, "n-1"); err != nil {
+		t.Fatalf("expected the default revocation store to be left alone ->> %s", err)
+	}
+
+	jwtVerifier, err := verifier.JWTVerifier()
+	if err != nil {
+		t.Fatalf("error loading verifier ->> %s", err)
+	}
+	if err := jwtVerifier.Revoke(idToken); err != nil {
+		t.Fatalf("error revoking ID token ->> %s", err)
+	}
+	if _, err := verifier.Verify(idToken, "n-1"); !errors.Is(err, jwtauth.ErrRevoked) {
+		t.Fatalf("expected ErrRevoked found %v", err)
+	}
+}
+
+func TestVerifyFromFiles(t *testing.T) {
+	provider := newTestProvider(t)
+	dir := t.TempDir()
+	keySet, err := provider.issuer.KeySet()
+	if err != nil {
+		t.Fatalf("error getting key set ->> %s", err)
+	}
+	jwksData, _ := json.Marshal(keySet)
+	discoveryData, _ := json.Marshal(oauth2.ProviderMetadata{Issuer: provider.server.URL, JWKSURI: "https://unreachable.example.com/jwks.json"})
+	jwksFile, discoveryFile := filepath.Join(dir, "jwks.json"), filepath.Join(dir, "openid-configuration")
+	if err := os.WriteFile(jwksFile, jwksData, 0600); err != nil {
+		t.Fatalf("error writing key set ->> %s", err)
+	}
+	if err := os.WriteFile(discoveryFile, discoveryData, 0600); err != nil {
+		t.Fatalf("error writing discovery document ->> %s", err)
+	}
+
+	verifier, err := NewVerifier(provider.server.URL, "app", WithDiscovery(discoveryFile), WithJWKS(jwksFile, time.Hour), WithAlgorithms("ES256"))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	if _, err := verifier.Verify(provider.idToken(t, nil), "n-1"); err != nil {
+		t.Fatalf("error verifying ID token ->> %s", err)
+	}
+
+	// The discovery document has to be the one of the issuer.
+	verifier, err = NewVerifier("https://other.example.com", "app", WithDiscovery(discoveryFile))
+	if err != nil {
+		t.Fatalf("error creating verifier ->> %s", err)
+	}
+	if _, err := verifier.Verify(provider.idToken(t, nil), ""); err == nil {
+		t.Fatal("expected the discovery document of another issuer to be refused")
+	}
+}
+
+func TestFilterSeveralIssuers(t *testing.T) {
+	first, second := newTestProvider(t), newTestProvider(t)
+	firstVerifier, _ := NewVerifier(first.server.URL, "app")
+	secondVerifier, _ := NewVerifier(second.server.URL, "app")
+	var subject string
+	handler := Filter(firstVerifier, secondVerifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := IDTokenFromContext(r.Context())
+		subject = claims.Subject
+	}))
+	serve := func(token string) int {
+		subject = ""
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	for _, provider := range []*testProvider{first, second} {
+		if code := serve(provider.idToken(t, nil)); code != http.StatusOK || subject != "alice" {
+			t.Fatalf("expected the ID token of %s to be accepted, found %d", provider.server.URL, code)
+		}
+	}
+	// A provider cannot pass its tokens off as the ones of another.
+	if code := serve(second.idToken(t, jwt.MapClaims{"iss": first.server.URL})); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, code)
+	}
+	if code := serve(second.idToken(t, jwt.MapClaims{"iss": "https://unknown.example.com"})); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, code)
+	}
+}
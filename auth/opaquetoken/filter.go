@@ -0,0 +1,68 @@
+package opaquetoken
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/dgrijalva/jwt-go"
+)
+
+type contextKey int
+
+const principalContextKey contextKey = iota
+
+// ContextWithPrincipal returns a copy of the context carrying the given record,
+// which is what Filter does with the record of a valid token.
+func ContextWithPrincipal(ctx context.Context, record Record) context.Context {
+	return context.WithValue(ctx, principalContextKey, record)
+}
+
+// PrincipalFromContext returns the record of the token Filter accepted.
+func PrincipalFromContext(ctx context.Context) (Record, bool) {
+	record, ok := ctx.Value(principalContextKey).(Record)
+	return record, ok
+}
+
+// Claims describes the record in the shape of jwt claims, with sub, scope, iat,
+// exp and the metadata, so jwtauth.RequireScopes and the like work with opaque tokens.
+func (r *Record) Claims() jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	for key, value := range r.Metadata {
+		claims[key] = value
+	}
+	claims["sub"] = r.Subject
+	claims["iat"] = r.CreatedAt.Unix()
+	if len(r.Scopes) > 0 {
+		claims["scope"] = strings.Join(r.Scopes, " ")
+	}
+	if !r.ExpiresAt.IsZero() {
+		claims["exp"] = r.ExpiresAt.Unix()
+	}
+	return claims
+}
+
+// Filter check if the request has a valid token of the manager, the record of the token
+// is passed on in the request context for PrincipalFromContext, and its claims for
+// jwtauth.ClaimsFromContext. Refused requests are answered like jwtauth filters do.
+func (m *Manager) Filter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := m.extractor.Extract(r)
+		if err != nil {
+			m.errorHandler(w, r, jwtauth.NewAuthError(err))
+			return
+		}
+		record, err := m.Validate(token)
+		if err == ErrExpired {
+			err = &jwtauth.TokenError{Reason: jwtauth.ErrTokenExpired, Err: err}
+		}
+		if err != nil {
+			m.errorHandler(w, r, jwtauth.NewAuthError(err))
+			return
+		}
+		ctx := ContextWithPrincipal(r.Context(), record)
+		ctx = jwtauth.ContextWithClaims(ctx, record.Claims())
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
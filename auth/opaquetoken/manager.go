@@ -0,0 +1,117 @@
+package opaquetoken
+
+import (
+	"time"
+
+	"github.com/bellomd/miniauth/auth/authenv"
+	"github.com/bellomd/miniauth/auth/jwtauth"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalid is returned for tokens that are unknown or were revoked.
+var ErrInvalid = errors.New("token is invalid")
+
+// ErrExpired is returned for tokens past their expiration.
+var ErrExpired = errors.New("token is expired")
+
+// Manager issues, validates and revokes the tokens of one prefix.
+type Manager struct {
+	prefix       string
+	store        TokenStore
+	extractor    jwtauth.Extractor
+	errorHandler jwtauth.ErrorHandler
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithExtractor sets how Filter reads the token from the request, by default
+// from the Authorization header with the Bearer scheme.
+func WithExtractor(extractor jwtauth.Extractor) Option {
+	return func(m *Manager) {
+		m.extractor = extractor
+	}
+}
+
+// WithErrorHandler sets how Filter answers requests it refuses, jwtauth.BearerErrorHandler by default.
+func WithErrorHandler(errorHandler jwtauth.ErrorHandler) Option {
+	return func(m *Manager) {
+		m.errorHandler = errorHandler
+	}
+}
+
+// NewManager creates a manager for tokens with the given prefix, such as "mat" for
+// tokens looking like mat_..., kept in the given store. The prefix is made of up
+// to ten lower case letters and digits.
+func NewManager(prefix string, store TokenStore, opts ...Option) (*Manager, error) {
+	if !validPrefix(prefix) {
+		return nil, errors.New("invalid prefix")
+	}
+	if store == nil {
+		return nil, errors.New("invalid store")
+	}
+	m := &Manager{
+		prefix:       prefix,
+		store:        store,
+		extractor:    jwtauth.BearerExtractor(authenv.AuthorizationHeader),
+		errorHandler: jwtauth.BearerErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Issue creates a token for the subject with the given scopes and metadata, which expires
+// after the given time to live, or never when it is zero. The token is returned only this
+// once, the store keeps nothing it could be recovered from.
+func (m *Manager) Issue(subject string, ttl time.Duration, scopes []string, metadata map[string]string) (token string, record Record, err error) {
+	if subject == "" {
+		return "", Record{}, errors.New("invalid subject")
+	}
+	token, err = generate(m.prefix)
+	if err != nil {
+		return "", Record{}, err
+	}
+	record = Record{
+		Hash:      Hash(token),
+		Subject:   subject,
+		Scopes:    scopes,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		record.ExpiresAt = record.CreatedAt.Add(ttl)
+	}
+	if err := m.store.Save(record); err != nil {
+		return "", Record{}, err
+	}
+	return token, record, nil
+}
+
+// Validate returns the record of the given token, ErrMalformed for anything that is not
+// a token of this manager, ErrInvalid for unknown tokens and ErrExpired for expired ones.
+func (m *Manager) Validate(token string) (Record, error) {
+	if err := check(m.prefix, token); err != nil {
+		return Record{}, err
+	}
+	record, err := m.store.Lookup(Hash(token))
+	if err == ErrNotFound {
+		return Record{}, ErrInvalid
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	if record.Expired() {
+		return Record{}, ErrExpired
+	}
+	return record, nil
+}
+
+// Revoke removes the given token from the store, it is rejected from then on.
+func (m *Manager) Revoke(token string) error {
+	if err := check(m.prefix, token); err != nil {
+		return err
+	}
+	return m.store.Delete(Hash(token))
+}
@@ -0,0 +1,83 @@
+package opaquetoken
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bellomd/miniauth/auth/jwtauth"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	manager, err := NewManager("mat", NewMemoryStore())
+	if err != nil {
+		t.Fatalf("error creating manager ->> %s", err)
+	}
+	return manager
+}
+
+func TestManagerValidate(t *testing.T) {
+	manager := newTestManager(t)
+	token, _, err := manager.Issue("someone", time.Hour, []string{"read"}, nil)
+	if err != nil {
+		t.Fatalf("error issuing token ->> %s", err)
+	}
+	record, err := manager.Validate(token)
+	if err != nil || record.Subject != "someone" {
+		t.Fatalf("expected a valid token for someone ->> %v", err)
+	}
+
+	expired, _, err := manager.Issue("someone", time.Nanosecond, nil, nil)
+	if err != nil {
+		t.Fatalf("error issuing token ->> %s", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := manager.Validate(expired); err != ErrExpired {
+		t.Fatalf("expected ErrExpired found %v", err)
+	}
+
+	if err := manager.Revoke(token); err != nil {
+		t.Fatalf("error revoking token ->> %s", err)
+	}
+	if _, err := manager.Validate(token); err != ErrInvalid {
+		t.Fatalf("expected ErrInvalid found %v", err)
+	}
+	if _, err := NewManager("Bad_Prefix", NewMemoryStore()); err == nil {
+		t.Fatal("expected error for an invalid prefix")
+	}
+}
+
+func TestManagerFilter(t *testing.T) {
+	manager := newTestManager(t)
+	token, _, err := manager.Issue("someone", 0, []string{"orders:read"}, nil)
+	if err != nil {
+		t.Fatalf("error issuing token ->> %s", err)
+	}
+	handler := manager.Filter(jwtauth.RequireScopes("orders:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record, ok := PrincipalFromContext(r.Context())
+		if !ok || record.Subject != "someone" {
+			t.Errorf("expected the principal in the context found %+v", record)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	serve := func(authorization string) int {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	if code := serve("Bearer " + token); code != http.StatusNoContent {
+		t.Fatalf("expected %d found %d", http.StatusNoContent, code)
+	}
+	if code := serve("Bearer mat_unknown"); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, code)
+	}
+	if code := serve(""); code != http.StatusUnauthorized {
+		t.Fatalf("expected %d found %d", http.StatusUnauthorized, code)
+	}
+}
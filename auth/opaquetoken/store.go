@@ -0,0 +1,170 @@
+package opaquetoken
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by stores for hashes they hold no record of.
+var ErrNotFound = errors.New("token not found")
+
+// Record is what a TokenStore keeps of a token, the token itself is never stored.
+type Record struct {
+	// Hash of the token, see Hash.
+	Hash string `json:"hash"`
+	// Subject the token was issued to.
+	Subject string `json:"sub"`
+	// Scopes the token grants.
+	Scopes []string `json:"scopes,omitempty"`
+	// Metadata of the token, such as a name to tell tokens of the same subject apart.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// CreatedAt is when the token was issued.
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the token stops being accepted, never when zero.
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the token stopped being accepted.
+func (r *Record) Expired() bool {
+	return !r.ExpiresAt.IsZero() && time.Now().After(r.ExpiresAt)
+}
+
+// TokenStore keeps the token records by hash, implementations must be safe for concurrent use.
+type TokenStore interface {
+	// Save stores the record, replacing the one with the same hash.
+	Save(record Record) error
+	// Lookup returns the record with the given hash, ErrNotFound when there is none.
+	Lookup(hash string) (Record, error)
+	// Delete removes the record with the given hash.
+	Delete(hash string) error
+}
+
+// MemoryStore keeps the token records in memory, they are lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]Record
+}
+
+// NewMemoryStore creates an empty in memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+// Save stores the record and drops the expired ones.
+func (s *MemoryStore) Save(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, stored := range s.records {
+		if stored.Expired() {
+			delete(s.records, hash)
+		}
+	}
+	s.records[record.Hash] = record
+	return nil
+}
+
+// Lookup returns the record with the given hash.
+func (s *MemoryStore) Lookup(hash string) (Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, found := s.records[hash]
+	if !found {
+		return Record{}, ErrNotFound
+	}
+	return record, nil
+}
+
+// Delete removes the record with the given hash.
+func (s *MemoryStore) Delete(hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, hash)
+	return nil
+}
+
+// snapshot returns the records as a list.
+func (s *MemoryStore) snapshot() []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]Record, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	return records
+}
+
+// FileStore keeps the token records in memory like MemoryStore and writes them to a JSON
+// file on every change, so they survive a restart. The file is meant for a single process,
+// processes sharing tokens need a shared store such as a database behind TokenStore.
+type FileStore struct {
+	*MemoryStore
+	path string
+
+	mu sync.Mutex
+}
+
+// NewFileStore creates a store backed by the file at the given path, loading the records it already holds.
+func NewFileStore(path string) (*FileStore, error) {
+	store := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read tokens from %s", path)
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, errors.Wrapf(err, "unable to read tokens from %s", path)
+	}
+	for _, record := range records {
+		if !record.Expired() {
+			store.records[record.Hash] = record
+		}
+	}
+	return store, nil
+}
+
+// Save stores the record and writes the file.
+func (s *FileStore) Save(record Record) error {
+	if err := s.MemoryStore.Save(record); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Delete removes the record with the given hash and writes the file.
+func (s *FileStore) Delete(hash string) error {
+	if err := s.MemoryStore.Delete(hash); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// save writes the records to a temporary file first, so a crash cannot leave half a file behind.
+func (s *FileStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(s.snapshot())
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "unable to write tokens to %s", s.path)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return errors.Wrapf(err, "unable to write tokens to %s", s.path)
+	}
+	if err := temp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to write tokens to %s", s.path)
+	}
+	return errors.Wrapf(os.Rename(temp.Name(), s.path), "unable to write tokens to %s", s.path)
+}
@@ -0,0 +1,44 @@
+package opaquetoken
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("error creating store ->> %s", err)
+	}
+	manager, err := NewManager("mat", store)
+	if err != nil {
+		t.Fatalf("error creating manager ->> %s", err)
+	}
+	token, _, err := manager.Issue("someone", time.Hour, []string{"read"}, map[string]string{"name": "ci"})
+	if err != nil {
+		t.Fatalf("error issuing token ->> %s", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading store ->> %s", err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Fatal("expected the store to keep only the hash of the token")
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("error reopening store ->> %s", err)
+	}
+	record, err := reopened.Lookup(Hash(token))
+	if err != nil {
+		t.Fatalf("expected the token to survive a restart ->> %s", err)
+	}
+	if record.Subject != "someone" || record.Metadata["name"] != "ci" || record.Scopes[0] != "read" {
+		t.Fatalf("unexpected record %+v", record)
+	}
+}
@@ -0,0 +1,42 @@
+package opaquetoken
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateFormat(t *testing.T) {
+	token, err := generate("mat")
+	if err != nil {
+		t.Fatalf("error generating token ->> %s", err)
+	}
+	if !strings.HasPrefix(token, "mat_") || len(token) != len("mat_")+randomLength+checksumLength {
+		t.Fatalf("unexpected token format %s", token)
+	}
+	if err := check("mat", token); err != nil {
+		t.Fatalf("expected the token to pass the check ->> %s", err)
+	}
+
+	other, _ := generate("mat")
+	if other == token {
+		t.Fatal("expected tokens to differ")
+	}
+}
+
+func TestCheckRejectsMistypedTokens(t *testing.T) {
+	token, err := generate("mat")
+	if err != nil {
+		t.Fatalf("error generating token ->> %s", err)
+	}
+	mistyped := []byte(token)
+	if mistyped[10] == 'a' {
+		mistyped[10] = 'b'
+	} else {
+		mistyped[10] = 'a'
+	}
+	for _, candidate := range []string{string(mistyped), "abc_" + token[4:], token[:len(token)-1], "mat_"} {
+		if err := check("mat", candidate); err != ErrMalformed {
+			t.Fatalf("expected ErrMalformed for %s found %v", candidate, err)
+		}
+	}
+}
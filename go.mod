@@ -1,6 +1,6 @@
 module github.com/bellomd/miniauth
 
-go 1.17
+go 1.18
 
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible